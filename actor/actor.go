@@ -0,0 +1,178 @@
+package actor
+
+import (
+	"errors"
+
+	"github.com/webriots/coro"
+	"github.com/webriots/coro/sched"
+)
+
+// Option configures an actor.
+type Option func(*config)
+
+type config struct {
+	name        string
+	maxRestarts int
+	coroOpts    []coro.Option
+}
+
+// WithName names the actor. The name is also given to the actor's
+// coroutines with coro.WithName.
+func WithName(name string) Option {
+	return func(c *config) {
+		c.name = name
+	}
+}
+
+// WithRestart restarts the actor's body when it panics, up to max
+// times over the actor's life. The message being handled when it
+// panicked is lost, but the rest of its mailbox is kept.
+func WithRestart(max int) Option {
+	return func(c *config) {
+		c.maxRestarts = max
+	}
+}
+
+// WithCoroutineOptions passes opts through to coro.New when creating
+// the actor's coroutines.
+func WithCoroutineOptions(opts ...coro.Option) Option {
+	return func(c *config) {
+		c.coroOpts = append(c.coroOpts, opts...)
+	}
+}
+
+// Actor is an actor handling messages of type Msg.
+type Actor[Msg any] struct {
+	s        *sched.Scheduler
+	body     func(a *Actor[Msg])
+	cfg      config
+	task     *sched.Task
+	mailbox  []Msg
+	restarts int
+	stopped  bool
+	err      error
+	exits    []func(error)
+}
+
+// Spawn starts an actor running body on s. The body receives messages
+// with a.Receive; when it returns, the actor stops.
+//
+// If the body panics and the actor is not restarted, the actor stops
+// with the panic's error. Unless a callback has been registered with
+// OnExit, the panic is then propagated by the scheduler's Run.
+func Spawn[Msg any](s *sched.Scheduler, body func(a *Actor[Msg]), opts ...Option) *Actor[Msg] {
+	a := &Actor[Msg]{s: s, body: body}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&a.cfg)
+		}
+	}
+	a.start()
+	return a
+}
+
+// start runs the actor's body in a new task.
+func (a *Actor[Msg]) start() {
+	opts := a.cfg.coroOpts
+	if a.cfg.name != "" {
+		opts = append([]coro.Option{coro.WithName(a.cfg.name)}, opts...)
+	}
+	a.task = a.s.Spawn(func(*sched.Task) { a.body(a) }, opts...)
+	a.task.OnExit(a.exited)
+}
+
+// exited restarts the actor after a panic if its policy allows, or
+// else stops it.
+func (a *Actor[Msg]) exited(err error) {
+	if a.stopped {
+		return
+	}
+	if err != nil && !errors.Is(err, coro.ErrCanceled) && a.restarts < a.cfg.maxRestarts {
+		a.restarts++
+		a.start()
+		return
+	}
+	a.stopped, a.err, a.mailbox = true, err, nil
+	for _, fn := range a.exits {
+		fn(err)
+	}
+	if err != nil && len(a.exits) == 0 && !errors.Is(err, coro.ErrCanceled) {
+		panic(err)
+	}
+}
+
+// Name returns the actor's name.
+func (a *Actor[Msg]) Name() string {
+	return a.cfg.name
+}
+
+// Task returns the task currently running the actor's body. An
+// actor's body uses it to wait for replies with Future.Await.
+func (a *Actor[Msg]) Task() *sched.Task {
+	return a.task
+}
+
+// Receive returns the next message from the actor's mailbox, parking
+// the actor until one arrives. It must only be called from the
+// actor's body.
+func (a *Actor[Msg]) Receive() Msg {
+	for len(a.mailbox) == 0 {
+		a.task.Park()
+	}
+	msg := a.mailbox[0]
+	var zero Msg
+	a.mailbox[0] = zero
+	a.mailbox = a.mailbox[1:]
+	return msg
+}
+
+// Send appends msg to the actor's mailbox, waking the actor if it is
+// waiting for a message. It reports false, dropping msg, if the actor
+// has stopped.
+func (a *Actor[Msg]) Send(msg Msg) bool {
+	if a.stopped {
+		return false
+	}
+	a.mailbox = append(a.mailbox, msg)
+	a.task.Wake()
+	return true
+}
+
+// Pending returns the number of messages waiting in the actor's
+// mailbox.
+func (a *Actor[Msg]) Pending() int {
+	return len(a.mailbox)
+}
+
+// Restarts returns the number of times the actor has been restarted.
+func (a *Actor[Msg]) Restarts() int {
+	return a.restarts
+}
+
+// Stop stops the actor, canceling its body and discarding its
+// mailbox. When called from the actor's own body, Stop does not
+// return.
+func (a *Actor[Msg]) Stop() {
+	if a.stopped {
+		return
+	}
+	a.task.Cancel()
+}
+
+// Stopped reports whether the actor has stopped.
+func (a *Actor[Msg]) Stopped() bool {
+	return a.stopped
+}
+
+// Err returns the error the actor stopped with: nil if its body
+// returned, an error wrapping coro.ErrCanceled if it was stopped, or
+// the error of the panic that stopped it.
+func (a *Actor[Msg]) Err() error {
+	return a.err
+}
+
+// OnExit registers fn to be called with the actor's error when it
+// stops. Registering a callback marks the actor's panics as observed.
+func (a *Actor[Msg]) OnExit(fn func(err error)) {
+	a.exits = append(a.exits, fn)
+}
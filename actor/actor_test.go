@@ -0,0 +1,159 @@
+package actor
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/webriots/coro"
+	"github.com/webriots/coro/sched"
+)
+
+type ping struct {
+	n    int
+	from *Actor[ping]
+}
+
+func TestActorPingPong(t *testing.T) {
+	s := sched.New()
+	var trace []string
+
+	player := func(name string) func(a *Actor[ping]) {
+		return func(a *Actor[ping]) {
+			for {
+				msg := a.Receive()
+				trace = append(trace, name+string(rune('0'+msg.n)))
+				if msg.n == 4 {
+					return
+				}
+				msg.from.Send(ping{n: msg.n + 1, from: a})
+			}
+		}
+	}
+	alice := Spawn(s, player("alice"), WithName("alice"))
+	bob := Spawn(s, player("bob"))
+
+	alice.Send(ping{n: 0, from: bob})
+	s.Run()
+
+	expected := []string{"alice0", "bob1", "alice2", "bob3", "alice4"}
+	if !reflect.DeepEqual(trace, expected) {
+		t.Errorf("Expected %v, got %v", expected, trace)
+	}
+	if !alice.Stopped() || alice.Err() != nil || alice.Name() != "alice" {
+		t.Errorf("Expected alice to stop cleanly, got %v", alice.Err())
+	}
+	if bob.Stopped() {
+		t.Error("Expected bob to be waiting for a message")
+	}
+	if alice.Send(ping{}) {
+		t.Error("Expected sending to a stopped actor to fail")
+	}
+}
+
+func TestActorMailbox(t *testing.T) {
+	s := sched.New()
+	var received []int
+	a := Spawn(s, func(a *Actor[int]) {
+		for {
+			received = append(received, a.Receive())
+		}
+	})
+
+	for i := range 3 {
+		a.Send(i)
+	}
+	if a.Pending() != 3 {
+		t.Errorf("Expected 3 pending messages, got %d", a.Pending())
+	}
+	s.Run()
+
+	if !reflect.DeepEqual(received, []int{0, 1, 2}) || a.Pending() != 0 {
+		t.Errorf("Expected messages in order, got %v", received)
+	}
+}
+
+func TestActorRestart(t *testing.T) {
+	s := sched.New()
+	var starts int
+	var handled []int
+	a := Spawn(s, func(a *Actor[int]) {
+		starts++
+		for {
+			n := a.Receive()
+			if n < 0 {
+				panic("negative")
+			}
+			handled = append(handled, n)
+		}
+	}, WithRestart(1))
+
+	for _, n := range []int{1, -1, 2, -2, 3} {
+		a.Send(n)
+	}
+	var exitErr error
+	a.OnExit(func(err error) { exitErr = err })
+	s.Run()
+
+	if starts != 2 || a.Restarts() != 1 {
+		t.Errorf("Expected one restart, got %d starts", starts)
+	}
+	if !reflect.DeepEqual(handled, []int{1, 2}) {
+		t.Errorf("Expected the mailbox to survive the restart, got %v", handled)
+	}
+	if !a.Stopped() || exitErr == nil || exitErr.Error() != "negative" || a.Err() != exitErr {
+		t.Errorf("Expected the second panic to stop the actor, got %v", exitErr)
+	}
+	if _, ok := exitErr.(interface{ ErrorWithStack() string }); !ok {
+		t.Error("Expected the error to carry a stack trace")
+	}
+}
+
+func TestActorPanicUnobserved(t *testing.T) {
+	s := sched.New()
+	a := Spawn(s, func(a *Actor[int]) { panic("test panic") })
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("Expected panic but got none")
+		}
+		if !a.Stopped() {
+			t.Error("Expected the actor to stop")
+		}
+	}()
+	s.Run()
+}
+
+func TestActorStop(t *testing.T) {
+	s := sched.New()
+	var unwound bool
+	a := Spawn(s, func(a *Actor[int]) {
+		defer func() { unwound = true }()
+		for {
+			if a.Receive() == 0 {
+				a.Stop()
+			}
+		}
+	}, WithRestart(5))
+
+	a.Send(1)
+	s.Run()
+	a.Stop()
+	if !unwound || !a.Stopped() || !errors.Is(a.Err(), coro.ErrCanceled) || a.Restarts() != 0 {
+		t.Errorf("Expected the actor to stop without restarting, got %v", a.Err())
+	}
+
+	b := Spawn(s, func(a *Actor[int]) {
+		for {
+			if a.Receive() == 0 {
+				a.Stop()
+			}
+		}
+	})
+	b.Send(0)
+	b.Send(1)
+	s.Run()
+	if !b.Stopped() || !errors.Is(b.Err(), coro.ErrCanceled) || b.Pending() != 0 {
+		t.Errorf("Expected the actor to stop itself, got %v", b.Err())
+	}
+}
@@ -0,0 +1,103 @@
+package actor
+
+import (
+	"errors"
+
+	"github.com/webriots/coro/sched"
+)
+
+var (
+	// ErrStopped is the error of a Future whose request was sent to
+	// an actor that had stopped.
+	ErrStopped = errors.New("actor: stopped")
+
+	// ErrPending is returned by Future.Result before the reply has
+	// arrived.
+	ErrPending = errors.New("actor: reply pending")
+)
+
+// Future is the eventual reply to a request made with Ask.
+type Future[T any] struct {
+	value   T
+	err     error
+	done    bool
+	waiters []*sched.Task
+}
+
+// Reply is how an actor answers a request made with Ask. Only the
+// first call to Send or Fail has any effect.
+type Reply[T any] struct {
+	f *Future[T]
+}
+
+// Send answers the request with v.
+func (r Reply[T]) Send(v T) {
+	r.f.resolve(v, nil)
+}
+
+// Fail answers the request with err.
+func (r Reply[T]) Fail(err error) {
+	var zero T
+	r.f.resolve(zero, err)
+}
+
+// Ask sends the message built by msg to a and returns a future for
+// the reply, which a sends with the Reply embedded in the message:
+//
+//	type Get struct {
+//		Key   string
+//		Reply actor.Reply[int]
+//	}
+//
+//	f := actor.Ask(store, func(r actor.Reply[int]) Get {
+//		return Get{Key: "x", Reply: r}
+//	})
+//	v, err := f.Await(self.Task())
+//
+// If a has stopped, the future fails with ErrStopped. A request that
+// a never answers, for example because it panics while handling it,
+// leaves the future pending.
+func Ask[Msg, T any](a *Actor[Msg], msg func(r Reply[T]) Msg) *Future[T] {
+	f := &Future[T]{}
+	if !a.Send(msg(Reply[T]{f: f})) {
+		var zero T
+		f.resolve(zero, ErrStopped)
+	}
+	return f
+}
+
+// Done reports whether the reply has arrived.
+func (f *Future[T]) Done() bool {
+	return f.done
+}
+
+// Result returns the reply, or ErrPending if it has not arrived.
+func (f *Future[T]) Result() (T, error) {
+	if !f.done {
+		var zero T
+		return zero, ErrPending
+	}
+	return f.value, f.err
+}
+
+// Await parks t until the reply arrives and returns it. It must only
+// be called from t, typically with the Task of the calling actor.
+func (f *Future[T]) Await(t *sched.Task) (T, error) {
+	for !f.done {
+		f.waiters = append(f.waiters, t)
+		t.Park()
+	}
+	return f.value, f.err
+}
+
+// resolve completes the future and wakes its waiters.
+func (f *Future[T]) resolve(v T, err error) {
+	if f.done {
+		return
+	}
+	f.value, f.err, f.done = v, err, true
+	for _, t := range f.waiters {
+		t.Wake()
+	}
+	f.waiters = nil
+}
@@ -0,0 +1,93 @@
+package actor
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/webriots/coro/sched"
+)
+
+type get struct {
+	key   string
+	reply Reply[int]
+}
+
+func store(values map[string]int) func(a *Actor[get]) {
+	return func(a *Actor[get]) {
+		for {
+			msg := a.Receive()
+			v, ok := values[msg.key]
+			if !ok {
+				msg.reply.Fail(errors.New("missing " + msg.key))
+				continue
+			}
+			msg.reply.Send(v)
+		}
+	}
+}
+
+func request(key string) func(Reply[int]) get {
+	return func(r Reply[int]) get { return get{key: key, reply: r} }
+}
+
+func TestAsk(t *testing.T) {
+	s := sched.New()
+	db := Spawn(s, store(map[string]int{"x": 1}))
+
+	f := Ask(db, request("x"))
+	if _, err := f.Result(); err != ErrPending || f.Done() {
+		t.Errorf("Expected ErrPending, got %v", err)
+	}
+	missing := Ask(db, request("y"))
+	s.Run()
+
+	if v, err := f.Result(); v != 1 || err != nil {
+		t.Errorf("Expected 1, got %d, %v", v, err)
+	}
+	if _, err := missing.Result(); err == nil || err.Error() != "missing y" {
+		t.Errorf("Expected the store's error, got %v", err)
+	}
+}
+
+func TestAskAwait(t *testing.T) {
+	s := sched.New()
+	db := Spawn(s, store(map[string]int{"x": 1, "y": 2}))
+
+	var sum int
+	client := Spawn(s, func(a *Actor[struct{}]) {
+		for _, key := range []string{"x", "y"} {
+			v, err := Ask(db, request(key)).Await(a.Task())
+			if err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+			sum += v
+		}
+	})
+	s.Run()
+
+	if sum != 3 || !client.Stopped() {
+		t.Errorf("Expected the client to sum both replies, got %d", sum)
+	}
+}
+
+func TestAskStopped(t *testing.T) {
+	s := sched.New()
+	db := Spawn(s, store(nil))
+	db.Stop()
+
+	f := Ask(db, request("x"))
+	if _, err := f.Result(); err != ErrStopped {
+		t.Errorf("Expected ErrStopped, got %v", err)
+	}
+}
+
+func TestReplyOnce(t *testing.T) {
+	f := &Future[int]{}
+	r := Reply[int]{f: f}
+	r.Send(1)
+	r.Fail(errors.New("late"))
+	r.Send(2)
+	if v, err := f.Result(); v != 1 || err != nil {
+		t.Errorf("Expected the first reply, got %d, %v", v, err)
+	}
+}
@@ -0,0 +1,11 @@
+// Package actor implements actors on top of coroutines. Each actor is
+// a task on a sched.Scheduler whose body handles the messages in its
+// mailbox one at a time, parking in Receive while the mailbox is
+// empty. Many actors share one goroutine, and because they only
+// switch when they wait for messages or replies, a system of actors
+// runs deterministically.
+//
+// Actors communicate with Send, or with Ask for request/reply. An
+// actor that panics can be restarted automatically with its mailbox
+// intact.
+package actor
@@ -0,0 +1,32 @@
+package coro
+
+import (
+	"testing"
+	"time"
+)
+
+func TestManualClock(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewManualClock(start)
+	if now := c.Now(); !now.Equal(start) {
+		t.Errorf("Expected %v, got %v", start, now)
+	}
+
+	c.Advance(time.Minute)
+	if now := c.Now(); !now.Equal(start.Add(time.Minute)) {
+		t.Errorf("Expected %v, got %v", start.Add(time.Minute), now)
+	}
+
+	c.Set(start)
+	if now := c.Now(); !now.Equal(start) {
+		t.Errorf("Expected %v, got %v", start, now)
+	}
+}
+
+func TestSystemClock(t *testing.T) {
+	before := time.Now()
+	now := SystemClock.Now()
+	if now.Before(before) || now.After(time.Now()) {
+		t.Errorf("Expected the current time, got %v", now)
+	}
+}
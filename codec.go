@@ -0,0 +1,50 @@
+package coro
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+)
+
+// Codec encodes values to bytes and decodes them again.
+//
+// Replay compares checksums of the encoded outputs of a coroutine, so
+// a codec used with Replayable must encode equal values to the same
+// bytes every time. JSONCodec does, since encoding/json sorts map
+// keys. GobCodec does not for values containing maps, which
+// encoding/gob writes in Go's randomized iteration order, so it should
+// only be used for outputs without maps.
+type Codec interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+var (
+	// JSONCodec encodes values with encoding/json.
+	JSONCodec Codec = jsonCodec{}
+
+	// GobCodec encodes each value as a self-contained encoding/gob
+	// stream. Its encoding of a map depends on iteration order, so it
+	// is not deterministic for values containing maps.
+	GobCodec Codec = gobCodec{}
+)
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v any) ([]byte, error) { return json.Marshal(v) }
+
+func (jsonCodec) Unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
+
+type gobCodec struct{}
+
+func (gobCodec) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) Unmarshal(data []byte, v any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
@@ -0,0 +1,39 @@
+package coro
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCodecs(t *testing.T) {
+	type point struct {
+		X, Y int
+		Tags []string
+	}
+	for name, codec := range map[string]Codec{"json": JSONCodec, "gob": GobCodec} {
+		in := point{X: 1, Y: -2, Tags: []string{"a", "b"}}
+		data, err := codec.Marshal(in)
+		if err != nil {
+			t.Fatalf("%s: expected no error, got %v", name, err)
+		}
+		var out point
+		if err := codec.Unmarshal(data, &out); err != nil {
+			t.Fatalf("%s: expected no error, got %v", name, err)
+		}
+		if !reflect.DeepEqual(in, out) {
+			t.Errorf("%s: expected %v, got %v", name, in, out)
+		}
+	}
+}
+
+func TestCodecErrors(t *testing.T) {
+	for name, codec := range map[string]Codec{"json": JSONCodec, "gob": GobCodec} {
+		if _, err := codec.Marshal(func() {}); err == nil {
+			t.Errorf("%s: expected an error encoding a function", name)
+		}
+		var out int
+		if err := codec.Unmarshal([]byte("garbage"), &out); err == nil {
+			t.Errorf("%s: expected an error decoding garbage", name)
+		}
+	}
+}
@@ -1,15 +1,17 @@
 package coro
 
 import (
-	"errors"
 	"fmt"
+	"runtime"
 	"unsafe"
+
+	"github.com/webriots/coro/internal/panics"
 )
 
 var (
 	// ErrCanceled is returned when a coroutine is canceled or when
 	// yield/suspend is called on a completed or canceled coroutine.
-	ErrCanceled = errors.New("coro: coroutine canceled")
+	ErrCanceled = panics.ErrCanceled
 	_           unsafe.Pointer
 )
 
@@ -45,8 +47,12 @@ func coroswitch(*coroutine)
 // The generic type parameters allow for strongly typed coroutines:
 //   - In: The type of values passed to the coroutine via resume
 //   - Out: The type of values returned from the coroutine via yield
+//
+// Options such as WithHooks may be passed after fn to customize the
+// coroutine's behavior.
 func New[In, Out any](
 	fn func(func(Out) In, func() In) Out,
+	opts ...Option,
 ) (resume func(In) (Out, bool), cancel func()) {
 	var (
 		c    *coroutine
@@ -54,15 +60,27 @@ func New[In, Out any](
 		out  Out
 		done bool
 		perr error
+		cfg  = newConfig(opts)
+		own  = cfg.track()
 	)
 
 	c = newcoro(func(c *coroutine) {
 		defer func() {
 			if !done {
-				if p := recover(); p != nil {
+				canceled := perr
+				p := recover()
+				if p != nil {
 					perr = newPanicError(p)
 				}
 				done = true
+				switch {
+				case canceled != nil && (p == nil || p == canceled):
+					cfg.onCancel()
+				case p != nil:
+					cfg.onPanic(perr)
+				default:
+					cfg.onComplete()
+				}
 			}
 		}()
 
@@ -71,7 +89,9 @@ func New[In, Out any](
 				panic(ErrCanceled)
 			}
 			out = val
+			cfg.onYield()
 			coroswitch(c)
+			cfg.applyLabels()
 			if perr != nil {
 				panic(perr)
 			}
@@ -82,7 +102,9 @@ func New[In, Out any](
 			if done {
 				panic(ErrCanceled)
 			}
+			cfg.onYield()
 			coroswitch(c)
+			cfg.applyLabels()
 			if perr != nil {
 				panic(perr)
 			}
@@ -90,11 +112,13 @@ func New[In, Out any](
 		}
 
 		if perr == nil {
+			cfg.applyLabels()
 			out = fn(yield, suspend)
 		}
 	})
 
 	resume = func(val In) (Out, bool) {
+		runtime.KeepAlive(own)
 		if perr != nil {
 			panic(perr)
 		}
@@ -103,7 +127,10 @@ func New[In, Out any](
 			return zero, false
 		}
 		in = val
+		cfg.beforeResume()
+		cfg.captureLabels()
 		coroswitch(c)
+		cfg.afterResume()
 		if perr != nil {
 			panic(perr)
 		}
@@ -111,11 +138,13 @@ func New[In, Out any](
 	}
 
 	cancel = func() {
+		runtime.KeepAlive(own)
 		if done {
 			return
 		}
 		canceled := fmt.Errorf("%w", ErrCanceled)
 		perr = canceled
+		cfg.captureLabels()
 		coroswitch(c)
 		if perr != nil && perr != canceled {
 			panic(perr)
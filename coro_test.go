@@ -761,3 +761,12 @@ func TestDebugString(t *testing.T) {
 
 	resume(0)
 }
+
+func BenchmarkNew(b *testing.B) {
+	for range b.N {
+		resume, _ := New(func(yield func(int) int, suspend func() int) int {
+			return 0
+		})
+		resume(0)
+	}
+}
@@ -0,0 +1,87 @@
+package corotest
+
+import (
+	"errors"
+	"reflect"
+
+	"github.com/webriots/coro"
+)
+
+// MaxResumes is the number of times Drain resumes a coroutine before
+// reporting that it never finishes.
+const MaxResumes = 1 << 20
+
+// TB is the subset of testing.TB used by the helpers, shared with
+// coro.VerifyNone.
+type TB = coro.TB
+
+// Drain resumes a coroutine with the zero input until it finishes,
+// and returns the values it yielded. Its return value is not
+// included. If the coroutine is still running after MaxResumes
+// resumes, Drain fails the test with Fatalf.
+func Drain[In, Out any](t TB, resume func(In) (Out, bool)) []Out {
+	t.Helper()
+	var in In
+	var outs []Out
+	for range MaxResumes {
+		out, running := resume(in)
+		if !running {
+			return outs
+		}
+		outs = append(outs, out)
+	}
+	t.Fatalf("Expected coroutine to finish within %d resumes", MaxResumes)
+	return nil
+}
+
+// ExpectYields resumes a coroutine with each of inputs in turn and
+// checks that the outputs equal want, as reported by
+// reflect.DeepEqual. The coroutine must keep running until the last
+// input, which may finish it, in which case its return value is
+// compared with the last element of want. Mismatches are reported
+// with Errorf; a coroutine that finishes early stops the comparison.
+func ExpectYields[In, Out any](t TB, resume func(In) (Out, bool), inputs []In, want []Out) {
+	t.Helper()
+	if len(inputs) != len(want) {
+		t.Fatalf("Expected as many outputs as inputs, got %d inputs and %d outputs", len(inputs), len(want))
+	}
+	for i, in := range inputs {
+		out, running := resume(in)
+		if !reflect.DeepEqual(out, want[i]) {
+			t.Errorf("Expected resume %d with %#v to produce %#v, got %#v", i, in, want[i], out)
+		}
+		if !running && i < len(inputs)-1 {
+			t.Errorf("Expected coroutine to run for %d resumes, finished after %d", len(inputs), i+1)
+			return
+		}
+	}
+}
+
+// ExpectPanic calls fn and checks that it panics with an error for
+// which errors.Is reports target, or with any value if target is nil.
+// It returns the panic value, or nil if fn returned normally.
+func ExpectPanic(t TB, fn func(), target error) (p any) {
+	t.Helper()
+	defer func() {
+		t.Helper()
+		p = recover()
+		switch err, ok := p.(error); {
+		case p == nil:
+			t.Errorf("Expected panic but got none")
+		case target == nil:
+		case !ok:
+			t.Errorf("Expected panic with %v, got %v", target, p)
+		case !errors.Is(err, target):
+			t.Errorf("Expected panic with %v, got %v", target, err)
+		}
+	}()
+	fn()
+	return nil
+}
+
+// ExpectCanceled calls fn and checks that it panics with an error
+// wrapping coro.ErrCanceled, as resuming a canceled coroutine does.
+func ExpectCanceled(t TB, fn func()) {
+	t.Helper()
+	ExpectPanic(t, fn, coro.ErrCanceled)
+}
@@ -0,0 +1,157 @@
+package corotest
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/webriots/coro"
+)
+
+// fakeTB records the failures reported through the TB interface.
+// Fatalf stops the calling goroutine, so helpers that may fail fatally
+// are run with run.
+type fakeTB struct {
+	errors []string
+	fatal  bool
+}
+
+func (f *fakeTB) Helper() {}
+
+func (f *fakeTB) Errorf(format string, args ...any) {
+	f.errors = append(f.errors, fmt.Sprintf(format, args...))
+}
+
+func (f *fakeTB) Fatalf(format string, args ...any) {
+	f.Errorf(format, args...)
+	f.fatal = true
+	runtime.Goexit()
+}
+
+// run calls fn on its own goroutine so that Fatalf can stop it.
+func (f *fakeTB) run(fn func()) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn()
+	}()
+	<-done
+}
+
+// count returns a coroutine that yields 0 to n-1 and returns n.
+func count(n int) (func(struct{}) (int, bool), func()) {
+	return coro.New(func(yield func(int) struct{}, suspend func() struct{}) int {
+		for i := range n {
+			yield(i)
+		}
+		return n
+	})
+}
+
+// adder returns a coroutine that yields the running total of its
+// inputs and returns it once the total exceeds 10.
+func adder() (func(int) (int, bool), func()) {
+	return coro.New(func(yield func(int) int, suspend func() int) int {
+		total := 0
+		for total <= 10 {
+			total += yield(total)
+		}
+		return total
+	})
+}
+
+func TestDrain(t *testing.T) {
+	resume, _ := count(3)
+	if outs := Drain(t, resume); !reflect.DeepEqual(outs, []int{0, 1, 2}) {
+		t.Errorf("Expected [0 1 2], got %v", outs)
+	}
+
+	resume, _ = count(0)
+	if outs := Drain(t, resume); len(outs) != 0 {
+		t.Errorf("Expected no outputs, got %v", outs)
+	}
+}
+
+func TestDrainEndless(t *testing.T) {
+	resume, cancel := coro.New(func(yield func(int) struct{}, suspend func() struct{}) int {
+		for {
+			yield(1)
+		}
+	})
+	defer ExpectCanceled(t, cancel)
+
+	var tb fakeTB
+	tb.run(func() { Drain(&tb, resume) })
+	if !tb.fatal || len(tb.errors) != 1 || !strings.Contains(tb.errors[0], "finish") {
+		t.Errorf("Expected a fatal failure, got %v", tb.errors)
+	}
+}
+
+func TestExpectYields(t *testing.T) {
+	resume, _ := adder()
+	ExpectYields(t, resume, []int{0, 1, 2, 3, 5}, []int{0, 1, 3, 6, 11})
+
+	var tb fakeTB
+	resume, _ = adder()
+	ExpectYields(&tb, resume, []int{0, 1, 2}, []int{0, 1, 4})
+	if len(tb.errors) != 1 || !strings.Contains(tb.errors[0], "resume 2") {
+		t.Errorf("Expected a mismatch at resume 2, got %v", tb.errors)
+	}
+
+	tb = fakeTB{}
+	resume, _ = adder()
+	ExpectYields(&tb, resume, []int{0, 20, 1}, []int{0, 20, 21})
+	if len(tb.errors) != 1 || !strings.Contains(tb.errors[0], "finished after 2") {
+		t.Errorf("Expected an early finish, got %v", tb.errors)
+	}
+
+	tb = fakeTB{}
+	tb.run(func() { ExpectYields(&tb, resume, []int{0}, nil) })
+	if !tb.fatal {
+		t.Errorf("Expected mismatched lengths to fail fatally, got %v", tb.errors)
+	}
+}
+
+func TestExpectPanic(t *testing.T) {
+	errBoom := errors.New("boom")
+	p := ExpectPanic(t, func() { panic(fmt.Errorf("wrapped: %w", errBoom)) }, errBoom)
+	if err, ok := p.(error); !ok || !errors.Is(err, errBoom) {
+		t.Errorf("Expected the panic value to be returned, got %v", p)
+	}
+	ExpectPanic(t, func() { panic("anything") }, nil)
+
+	tests := []struct {
+		name   string
+		fn     func()
+		target error
+		want   string
+	}{
+		{"none", func() {}, nil, "got none"},
+		{"other error", func() { panic(errors.New("other")) }, errBoom, "got other"},
+		{"not an error", func() { panic(42) }, errBoom, "got 42"},
+	}
+	for _, tt := range tests {
+		var tb fakeTB
+		ExpectPanic(&tb, tt.fn, tt.target)
+		if len(tb.errors) != 1 || !strings.Contains(tb.errors[0], tt.want) {
+			t.Errorf("%s: Expected failure containing %q, got %v", tt.name, tt.want, tb.errors)
+		}
+	}
+}
+
+func TestExpectCanceled(t *testing.T) {
+	resume, cancel := count(3)
+	resume(struct{}{})
+	ExpectCanceled(t, cancel)
+	ExpectCanceled(t, func() { resume(struct{}{}) })
+
+	var tb fakeTB
+	resume, _ = count(3)
+	ExpectCanceled(&tb, func() { resume(struct{}{}) })
+	if len(tb.errors) != 1 {
+		t.Errorf("Expected a running coroutine to fail the check, got %v", tb.errors)
+	}
+}
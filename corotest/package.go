@@ -0,0 +1,12 @@
+// Package corotest provides helpers for testing coroutines. Instead
+// of resuming a coroutine by hand, comparing each output and
+// recovering the panics that report failures, a test can drain a
+// coroutine with Drain, check its outputs against the inputs that
+// produce them with ExpectYields, and assert on panics and
+// cancellation with ExpectPanic and ExpectCanceled.
+//
+// A Recorder logs every resume of a coroutine together with what it
+// yielded or returned, and Golden compares such a trace against a
+// golden file, which is rewritten when tests are run with
+// -corotest.update.
+package corotest
@@ -0,0 +1,117 @@
+package corotest
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var update = flag.Bool("corotest.update", false, "rewrite golden files with the traces produced by tests")
+
+// Recorder wraps a coroutine's resume function and logs every resume
+// along with the value the coroutine yielded, returned or panicked
+// with. A Recorder must not be used from multiple goroutines at once.
+type Recorder[In, Out any] struct {
+	resume func(In) (Out, bool)
+	cancel func()
+	lines  []string
+}
+
+// Record returns a recorder for the coroutine with the given resume
+// and cancel functions. cancel may be nil if the coroutine is never
+// canceled through the recorder.
+func Record[In, Out any](resume func(In) (Out, bool), cancel func()) *Recorder[In, Out] {
+	return &Recorder[In, Out]{resume: resume, cancel: cancel}
+}
+
+// Resume resumes the coroutine with in and logs the outcome. A panic
+// is logged and then propagated.
+func (r *Recorder[In, Out]) Resume(in In) (out Out, running bool) {
+	line := "resume " + describe(in)
+	defer func() {
+		if p := recover(); p != nil {
+			r.lines = append(r.lines, line+" -> panic "+firstLine(describe(p)))
+			panic(p)
+		}
+		verb := " -> yield "
+		if !running {
+			verb = " -> return "
+		}
+		r.lines = append(r.lines, line+verb+describe(out))
+	}()
+	return r.resume(in)
+}
+
+// Cancel cancels the coroutine and logs it. A panic raised by the
+// cancellation is logged and then propagated.
+func (r *Recorder[In, Out]) Cancel() {
+	defer func() {
+		if p := recover(); p != nil {
+			r.lines = append(r.lines, "cancel -> panic "+firstLine(describe(p)))
+			panic(p)
+		}
+		r.lines = append(r.lines, "cancel")
+	}()
+	r.cancel()
+}
+
+// Logf adds a line of annotation to the trace.
+func (r *Recorder[In, Out]) Logf(format string, args ...any) {
+	r.lines = append(r.lines, "# "+fmt.Sprintf(format, args...))
+}
+
+// Trace returns the lines logged so far, one per line.
+func (r *Recorder[In, Out]) Trace() string {
+	if len(r.lines) == 0 {
+		return ""
+	}
+	return strings.Join(r.lines, "\n") + "\n"
+}
+
+// Golden compares got with the contents of the golden file at path,
+// and reports a mismatch with Errorf. When tests are run with
+// -corotest.update, Golden instead writes got to the file, creating
+// its directory if needed.
+func Golden(t TB, path, got string) {
+	t.Helper()
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("Failed to create golden directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatalf("Failed to write golden file: %v", err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Golden file %s does not exist; run with -corotest.update to create it", path)
+		return
+	}
+	if err != nil {
+		t.Fatalf("Failed to read golden file: %v", err)
+	}
+	if !bytes.Equal(want, []byte(got)) {
+		t.Errorf("Trace does not match %s\nexpected:\n%s\ngot:\n%s", path, want, got)
+	}
+}
+
+// firstLine returns s up to its first newline, dropping the stack
+// traces that coroutine panics carry.
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
+
+// describe formats a value for a trace.
+func describe(v any) string {
+	if err, ok := v.(error); ok {
+		return err.Error()
+	}
+	return fmt.Sprintf("%#v", v)
+}
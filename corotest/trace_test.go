@@ -0,0 +1,68 @@
+package corotest
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecorder(t *testing.T) {
+	rec := Record(adder())
+	rec.Resume(0)
+	rec.Logf("adding %d", 4)
+	rec.Resume(4)
+	rec.Resume(9)
+	Golden(t, filepath.Join("testdata", "adder.golden"), rec.Trace())
+}
+
+func TestRecorderCancel(t *testing.T) {
+	rec := Record(count(3))
+	rec.Resume(struct{}{})
+	ExpectCanceled(t, rec.Cancel)
+	ExpectCanceled(t, func() { rec.Resume(struct{}{}) })
+
+	lines := strings.Split(strings.TrimSuffix(rec.Trace(), "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected 3 lines, got %q", lines)
+	}
+	if lines[0] != "resume struct {}{} -> yield 0" {
+		t.Errorf("Expected a yield, got %q", lines[0])
+	}
+	for _, line := range lines[1:] {
+		if !strings.Contains(line, "-> panic") || !strings.Contains(line, "canceled") {
+			t.Errorf("Expected a cancellation panic, got %q", line)
+		}
+	}
+}
+
+func TestGolden(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trace.golden")
+
+	var tb fakeTB
+	Golden(&tb, path, "resume 0 -> yield 0\n")
+	if len(tb.errors) != 1 || !strings.Contains(tb.errors[0], "does not exist") {
+		t.Errorf("Expected a missing golden file, got %v", tb.errors)
+	}
+
+	*update = true
+	tb = fakeTB{}
+	Golden(&tb, path, "resume 0 -> yield 0\n")
+	*update = false
+	if len(tb.errors) != 0 {
+		t.Errorf("Expected the golden file to be written, got %v", tb.errors)
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != "resume 0 -> yield 0\n" {
+		t.Errorf("Expected the golden file to hold the trace, got %q, %v", data, err)
+	}
+
+	tb = fakeTB{}
+	Golden(&tb, path, "resume 0 -> yield 0\n")
+	if len(tb.errors) != 0 {
+		t.Errorf("Expected a match, got %v", tb.errors)
+	}
+	Golden(&tb, path, "resume 0 -> return 0\n")
+	if len(tb.errors) != 1 || !strings.Contains(tb.errors[0], "does not match") {
+		t.Errorf("Expected a mismatch, got %v", tb.errors)
+	}
+}
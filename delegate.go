@@ -0,0 +1,84 @@
+package coro
+
+import "github.com/webriots/coro/internal/panics"
+
+// Delegate hands control of the calling coroutine to an inner
+// coroutine until the inner one terminates, like Python's
+// "yield from". It must be called from within a coroutine function,
+// with that function's yield.
+//
+// Parameters:
+//   - yield: The yield function of the calling (outer) coroutine.
+//   - in: The value passed to the inner coroutine's first resume.
+//   - resume, cancel: The inner coroutine, as returned by New.
+//
+// Every value the inner coroutine yields is yielded by the outer
+// coroutine, and every value the outer coroutine is resumed with is
+// forwarded to the inner one. Delegate returns the inner coroutine's
+// final return value. Since resume reports a suspend of the inner
+// coroutine just like a yield, an inner suspend makes the outer
+// coroutine yield the inner one's last value again; use YieldFrom to
+// forward suspends as suspends.
+//
+// Panics propagate in both directions: a panic in the inner coroutine
+// is raised by Delegate in the outer one, and if the outer coroutine
+// is canceled while delegating, the inner coroutine is canceled
+// before the cancellation continues to unwind the outer one.
+func Delegate[In, Out any](
+	yield func(Out) In,
+	in In,
+	resume func(In) (Out, bool),
+	cancel func(),
+) Out {
+	return delegate(yield, nil, in, resume, cancel, nil)
+}
+
+// YieldFrom creates an inner coroutine from fn and delegates to it
+// like Delegate, returning its final return value. It must be called
+// with the yield and suspend functions of the calling coroutine: the
+// inner coroutine's yields are yielded by the outer one, and its
+// suspends suspend the outer one, so the caller sees exactly what the
+// inner coroutine produces. Options are passed through to New.
+func YieldFrom[In, Out any](
+	yield func(Out) In,
+	suspend func() In,
+	in In,
+	fn func(func(Out) In, func() In) Out,
+	opts ...Option,
+) Out {
+	var suspended bool
+	resume, cancel := New(func(yield func(Out) In, innerSuspend func() In) Out {
+		return fn(yield, func() In {
+			suspended = true
+			return innerSuspend()
+		})
+	}, opts...)
+	return delegate(yield, suspend, in, resume, cancel, &suspended)
+}
+
+// delegate runs the inner coroutine for Delegate and YieldFrom. If
+// suspended is not nil, it is set by the inner coroutine when it
+// suspends, and the outer coroutine then suspends too.
+func delegate[In, Out any](
+	yield func(Out) In,
+	suspend func() In,
+	in In,
+	resume func(In) (Out, bool),
+	cancel func(),
+	suspended *bool,
+) Out {
+	defer panics.Cancel(cancel)
+
+	for {
+		out, running := resume(in)
+		if !running {
+			return out
+		}
+		if suspended != nil && *suspended {
+			*suspended = false
+			in = suspend()
+			continue
+		}
+		in = yield(out)
+	}
+}
@@ -0,0 +1,190 @@
+package coro
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type tree struct {
+	left, right *tree
+	value       int
+}
+
+// walk returns a coroutine function that yields the values of t in
+// order, delegating to a sub-coroutine for each subtree, and returns
+// the number of values yielded.
+func walk(t *tree) func(func(int) struct{}, func() struct{}) int {
+	return func(yield func(int) struct{}, suspend func() struct{}) int {
+		if t == nil {
+			return 0
+		}
+		n := YieldFrom(yield, suspend, struct{}{}, walk(t.left))
+		yield(t.value)
+		return n + 1 + YieldFrom(yield, suspend, struct{}{}, walk(t.right))
+	}
+}
+
+func TestDelegateRecursive(t *testing.T) {
+	root := &tree{
+		left:  &tree{left: &tree{value: 1}, value: 2},
+		value: 3,
+		right: &tree{value: 4, right: &tree{value: 5}},
+	}
+
+	resume, cancel := New(walk(root))
+	defer cancel()
+
+	var values []int
+	for {
+		v, running := resume(struct{}{})
+		if !running {
+			if v != 5 {
+				t.Errorf("Expected walk to return 5, got %d", v)
+			}
+			break
+		}
+		values = append(values, v)
+	}
+	if expected := []int{1, 2, 3, 4, 5}; !reflect.DeepEqual(values, expected) {
+		t.Errorf("Expected %v, got %v", expected, values)
+	}
+}
+
+func TestDelegateForwardsInput(t *testing.T) {
+	inner := func(yield func(string) int, suspend func() int) string {
+		a := yield("first")
+		b := yield("second")
+		if a != 2 || b != 3 {
+			t.Errorf("Expected inner to receive 2 and 3, got %d and %d", a, b)
+		}
+		return "inner done"
+	}
+
+	resume, cancel := New(func(yield func(string) int, suspend func() int) string {
+		in := yield("outer")
+		resumeInner, cancelInner := New(inner)
+		return Delegate(yield, in, resumeInner, cancelInner)
+	})
+	defer cancel()
+
+	for i, expected := range []string{"outer", "first", "second", "inner done"} {
+		out, _ := resume(i)
+		if out != expected {
+			t.Errorf("Expected '%s', got '%s'", expected, out)
+		}
+	}
+}
+
+func TestDelegateSuspend(t *testing.T) {
+	var yields int
+	var received []int
+	resume, cancel := New(func(yield func(int) int, suspend func() int) int {
+		counted := func(v int) int {
+			yields++
+			return yield(v)
+		}
+		return YieldFrom(counted, suspend, 0, func(yield func(int) int, suspend func() int) int {
+			received = append(received, yield(7))
+			received = append(received, suspend())
+			return 9
+		})
+	})
+	defer cancel()
+
+	var outs []int
+	for i := 1; ; i++ {
+		out, running := resume(i)
+		outs = append(outs, out)
+		if !running {
+			break
+		}
+	}
+
+	// The inner suspend suspends the outer coroutine rather than
+	// yielding, and the caller sees what it would see from the inner
+	// coroutine itself: a suspend repeats the last yielded value.
+	if yields != 1 {
+		t.Errorf("Expected the outer coroutine to yield once, got %d", yields)
+	}
+	if expected := []int{7, 7, 9}; !reflect.DeepEqual(outs, expected) {
+		t.Errorf("Expected %v, got %v", expected, outs)
+	}
+	if expected := []int{2, 3}; !reflect.DeepEqual(received, expected) {
+		t.Errorf("Expected inner to receive %v, got %v", expected, received)
+	}
+}
+
+func TestDelegateCancel(t *testing.T) {
+	var innerCanceled, outerCanceled bool
+	var events []string
+
+	resume, cancel := New(func(yield func(int) int, suspend func() int) int {
+		defer func() {
+			p := recover()
+			outerCanceled = p != nil && errors.Is(p.(error), ErrCanceled)
+		}()
+		return YieldFrom(yield, suspend, 0, func(yield func(int) int, suspend func() int) int {
+			defer func() { innerCanceled = recover() != nil }()
+			for {
+				yield(1)
+			}
+		}, WithHooks(recordHooks(&events)))
+	})
+
+	resume(0)
+	cancel()
+
+	if !innerCanceled || !outerCanceled {
+		t.Errorf("Expected both coroutines to be canceled, got %t and %t", innerCanceled, outerCanceled)
+	}
+	if expected := []string{"before", "yield", "after", "cancel"}; !reflect.DeepEqual(events, expected) {
+		t.Errorf("Expected inner events %v, got %v", expected, events)
+	}
+}
+
+func TestDelegateCancelUnrecovered(t *testing.T) {
+	var outer []string
+	resume, cancel := New(func(yield func(int) int, suspend func() int) int {
+		return YieldFrom(yield, suspend, 0, func(yield func(int) int, suspend func() int) int {
+			return yield(1)
+		})
+	}, WithHooks(recordHooks(&outer)))
+
+	resume(0)
+	func() {
+		defer func() {
+			r := recover()
+			if err, ok := r.(error); !ok || !errors.Is(err, ErrCanceled) {
+				t.Errorf("Expected ErrCanceled, got '%v'", r)
+			}
+		}()
+		cancel()
+	}()
+
+	if expected := []string{"before", "yield", "after", "cancel"}; !reflect.DeepEqual(outer, expected) {
+		t.Errorf("Expected outer events %v, got %v", expected, outer)
+	}
+}
+
+func TestDelegatePanic(t *testing.T) {
+	resume, cancel := New(func(yield func(int) int, suspend func() int) int {
+		return YieldFrom(yield, suspend, 0, func(yield func(int) int, suspend func() int) int {
+			yield(1)
+			panic("inner panic")
+		})
+	})
+	defer cancel()
+
+	resume(0)
+	defer func() {
+		r := recover()
+		if r == nil {
+			t.Fatal("Expected panic but got none")
+		}
+		if err, ok := r.(error); !ok || err.Error() != "inner panic" {
+			t.Errorf("Expected panic 'inner panic', got '%v'", r)
+		}
+	}()
+	resume(0)
+}
@@ -0,0 +1,224 @@
+package gen
+
+// Pair holds one value from each of two generators, as produced by
+// Zip and Enumerate.
+type Pair[A, B any] struct {
+	First  A
+	Second B
+}
+
+// Map returns a generator that applies f to each value of g.
+func Map[T, U any](g *Generator[T], f func(T) U) *Generator[U] {
+	return New(func(yield func(U)) {
+		defer g.Stop()
+		for {
+			v, ok := g.Next()
+			if !ok {
+				return
+			}
+			yield(f(v))
+		}
+	})
+}
+
+// Filter returns a generator that produces the values of g for which
+// keep returns true.
+func Filter[T any](g *Generator[T], keep func(T) bool) *Generator[T] {
+	return New(func(yield func(T)) {
+		defer g.Stop()
+		for {
+			v, ok := g.Next()
+			if !ok {
+				return
+			}
+			if keep(v) {
+				yield(v)
+			}
+		}
+	})
+}
+
+// Take returns a generator that produces at most the first n values
+// of g. g is stopped as soon as the n-th value has been produced.
+func Take[T any](g *Generator[T], n int) *Generator[T] {
+	return New(func(yield func(T)) {
+		defer g.Stop()
+		for i := 0; i < n; i++ {
+			v, ok := g.Next()
+			if !ok {
+				return
+			}
+			if i == n-1 {
+				g.Stop()
+			}
+			yield(v)
+		}
+	})
+}
+
+// Drop returns a generator that skips the first n values of g and
+// produces the rest.
+func Drop[T any](g *Generator[T], n int) *Generator[T] {
+	return New(func(yield func(T)) {
+		defer g.Stop()
+		for i := 0; ; i++ {
+			v, ok := g.Next()
+			if !ok {
+				return
+			}
+			if i >= n {
+				yield(v)
+			}
+		}
+	})
+}
+
+// TakeWhile returns a generator that produces values of g while keep
+// returns true. g is stopped at the first value for which keep
+// returns false.
+func TakeWhile[T any](g *Generator[T], keep func(T) bool) *Generator[T] {
+	return New(func(yield func(T)) {
+		defer g.Stop()
+		for {
+			v, ok := g.Next()
+			if !ok || !keep(v) {
+				return
+			}
+			yield(v)
+		}
+	})
+}
+
+// Zip returns a generator that pairs up the values of a and b. It
+// ends, stopping both, as soon as either is exhausted.
+func Zip[A, B any](a *Generator[A], b *Generator[B]) *Generator[Pair[A, B]] {
+	return New(func(yield func(Pair[A, B])) {
+		defer a.Stop()
+		defer b.Stop()
+		for {
+			x, ok := a.Next()
+			if !ok {
+				return
+			}
+			y, ok := b.Next()
+			if !ok {
+				return
+			}
+			yield(Pair[A, B]{x, y})
+		}
+	})
+}
+
+// Chain returns a generator that produces all values of each of gs
+// in turn.
+func Chain[T any](gs ...*Generator[T]) *Generator[T] {
+	return New(func(yield func(T)) {
+		defer func() {
+			for _, g := range gs {
+				g.Stop()
+			}
+		}()
+		for _, g := range gs {
+			for {
+				v, ok := g.Next()
+				if !ok {
+					break
+				}
+				yield(v)
+			}
+		}
+	})
+}
+
+// Flatten returns a generator that produces all values of each
+// generator produced by g in turn.
+func Flatten[T any](g *Generator[*Generator[T]]) *Generator[T] {
+	return New(func(yield func(T)) {
+		defer g.Stop()
+		for {
+			inner, ok := g.Next()
+			if !ok {
+				return
+			}
+			func() {
+				defer inner.Stop()
+				for {
+					v, ok := inner.Next()
+					if !ok {
+						return
+					}
+					yield(v)
+				}
+			}()
+		}
+	})
+}
+
+// Enumerate returns a generator that pairs each value of g with its
+// zero-based index.
+func Enumerate[T any](g *Generator[T]) *Generator[Pair[int, T]] {
+	return New(func(yield func(Pair[int, T])) {
+		defer g.Stop()
+		for i := 0; ; i++ {
+			v, ok := g.Next()
+			if !ok {
+				return
+			}
+			yield(Pair[int, T]{i, v})
+		}
+	})
+}
+
+// Window returns a generator that produces each run of size
+// consecutive values of g, sliding forward one value at a time. Each
+// window is a new slice. If g has fewer than size values, no windows
+// are produced. Window panics if size is not positive.
+func Window[T any](g *Generator[T], size int) *Generator[[]T] {
+	if size <= 0 {
+		panic("gen: window size must be positive")
+	}
+	return New(func(yield func([]T)) {
+		defer g.Stop()
+		window := make([]T, 0, size)
+		for {
+			v, ok := g.Next()
+			if !ok {
+				return
+			}
+			if len(window) == size {
+				window = window[1:]
+			}
+			window = append(window, v)
+			if len(window) == size {
+				yield(append([]T(nil), window...))
+			}
+		}
+	})
+}
+
+// Chunk returns a generator that splits the values of g into
+// consecutive slices of size values. The last chunk may be shorter.
+// Chunk panics if size is not positive.
+func Chunk[T any](g *Generator[T], size int) *Generator[[]T] {
+	if size <= 0 {
+		panic("gen: chunk size must be positive")
+	}
+	return New(func(yield func([]T)) {
+		defer g.Stop()
+		chunk := make([]T, 0, size)
+		for {
+			v, ok := g.Next()
+			if !ok {
+				break
+			}
+			chunk = append(chunk, v)
+			if len(chunk) == size {
+				yield(chunk)
+				chunk = make([]T, 0, size)
+			}
+		}
+		if len(chunk) > 0 {
+			yield(chunk)
+		}
+	})
+}
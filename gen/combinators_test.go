@@ -0,0 +1,191 @@
+package gen
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestMap(t *testing.T) {
+	values := Collect(Map(Of(1, 2, 3), strconv.Itoa))
+	if expected := []string{"1", "2", "3"}; !reflect.DeepEqual(values, expected) {
+		t.Errorf("Expected %v, got %v", expected, values)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	values := Collect(Filter(Of(1, 2, 3, 4), func(v int) bool { return v%2 == 0 }))
+	if expected := []int{2, 4}; !reflect.DeepEqual(values, expected) {
+		t.Errorf("Expected %v, got %v", expected, values)
+	}
+}
+
+func TestTake(t *testing.T) {
+	var stopped bool
+	g := Take(counter(&stopped), 3)
+
+	for _, expected := range []int{0, 1, 2} {
+		if v, _ := g.Next(); v != expected {
+			t.Errorf("Expected %d, got %d", expected, v)
+		}
+	}
+	if !stopped {
+		t.Error("Expected upstream to be stopped after the last value")
+	}
+	if _, ok := g.Next(); ok {
+		t.Error("Expected Take to be exhausted")
+	}
+
+	if values := Collect(Take(Of(1), 3)); !reflect.DeepEqual(values, []int{1}) {
+		t.Errorf("Expected [1], got %v", values)
+	}
+	if values := Collect(Take(Of(1), 0)); values != nil {
+		t.Errorf("Expected no values, got %v", values)
+	}
+}
+
+func TestDrop(t *testing.T) {
+	values := Collect(Drop(Of(1, 2, 3), 2))
+	if expected := []int{3}; !reflect.DeepEqual(values, expected) {
+		t.Errorf("Expected %v, got %v", expected, values)
+	}
+}
+
+func TestTakeWhile(t *testing.T) {
+	var stopped bool
+	values := Collect(TakeWhile(counter(&stopped), func(v int) bool { return v < 3 }))
+	if expected := []int{0, 1, 2}; !reflect.DeepEqual(values, expected) {
+		t.Errorf("Expected %v, got %v", expected, values)
+	}
+	if !stopped {
+		t.Error("Expected upstream to be stopped")
+	}
+}
+
+func TestZip(t *testing.T) {
+	var stopped bool
+	values := Collect(Zip(Of("a", "b"), counter(&stopped)))
+	expected := []Pair[string, int]{{"a", 0}, {"b", 1}}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("Expected %v, got %v", expected, values)
+	}
+	if !stopped {
+		t.Error("Expected the longer generator to be stopped")
+	}
+}
+
+func TestChain(t *testing.T) {
+	values := Collect(Chain(Of(1, 2), Of[int](), Of(3)))
+	if expected := []int{1, 2, 3}; !reflect.DeepEqual(values, expected) {
+		t.Errorf("Expected %v, got %v", expected, values)
+	}
+
+	var stopped bool
+	first, second := counter(&stopped), Of(1)
+	g := Chain(first, second)
+	g.Next()
+	g.Stop()
+	if !stopped {
+		t.Error("Expected the running generator to be stopped")
+	}
+	if _, ok := second.Next(); ok {
+		t.Error("Expected the pending generator to be stopped")
+	}
+}
+
+func TestFlatten(t *testing.T) {
+	values := Collect(Flatten(Of(Of(1, 2), Of[int](), Of(3))))
+	if expected := []int{1, 2, 3}; !reflect.DeepEqual(values, expected) {
+		t.Errorf("Expected %v, got %v", expected, values)
+	}
+
+	var outer, inner bool
+	g := Flatten(New(func(yield func(*Generator[int])) {
+		defer func() { outer = true }()
+		yield(counter(&inner))
+	}))
+	g.Next()
+	g.Stop()
+	if !outer || !inner {
+		t.Errorf("Expected outer and inner generators to be stopped, got %t and %t", outer, inner)
+	}
+}
+
+func TestEnumerate(t *testing.T) {
+	values := Collect(Enumerate(Of("a", "b")))
+	expected := []Pair[int, string]{{0, "a"}, {1, "b"}}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("Expected %v, got %v", expected, values)
+	}
+}
+
+func TestWindow(t *testing.T) {
+	values := Collect(Window(Of(1, 2, 3, 4), 3))
+	if expected := [][]int{{1, 2, 3}, {2, 3, 4}}; !reflect.DeepEqual(values, expected) {
+		t.Errorf("Expected %v, got %v", expected, values)
+	}
+	if values := Collect(Window(Of(1, 2), 3)); values != nil {
+		t.Errorf("Expected no windows, got %v", values)
+	}
+}
+
+func TestChunk(t *testing.T) {
+	values := Collect(Chunk(Of(1, 2, 3, 4, 5), 2))
+	if expected := [][]int{{1, 2}, {3, 4}, {5}}; !reflect.DeepEqual(values, expected) {
+		t.Errorf("Expected %v, got %v", expected, values)
+	}
+}
+
+func TestInvalidSize(t *testing.T) {
+	for name, fn := range map[string]func(){
+		"Window": func() { Window(Of(1), 0) },
+		"Chunk":  func() { Chunk(Of(1), -1) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Expected %s to panic", name)
+				}
+			}()
+			fn()
+		}()
+	}
+}
+
+func TestStopPropagatesThroughPipeline(t *testing.T) {
+	var stopped bool
+	g := Chunk(Map(Filter(counter(&stopped), func(v int) bool { return v%2 == 0 }), strconv.Itoa), 2)
+
+	chunk, _ := g.Next()
+	if expected := []string{"0", "2"}; !reflect.DeepEqual(chunk, expected) {
+		t.Errorf("Expected %v, got %v", expected, chunk)
+	}
+	g.Stop()
+	if !stopped {
+		t.Error("Expected the source to be stopped")
+	}
+}
+
+func TestPanicStopsUpstream(t *testing.T) {
+	var stopped bool
+	g := Map(counter(&stopped), func(v int) int {
+		if v == 1 {
+			panic("test panic")
+		}
+		return v
+	})
+	defer g.Stop()
+
+	g.Next()
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("Expected panic but got none")
+			}
+		}()
+		g.Next()
+	}()
+	if !stopped {
+		t.Error("Expected upstream to be stopped when a combinator panics")
+	}
+}
@@ -0,0 +1,93 @@
+package gen
+
+import (
+	"iter"
+
+	"github.com/webriots/coro"
+	"github.com/webriots/coro/internal/panics"
+)
+
+// Generator is a lazily evaluated sequence of values produced by a
+// coroutine. A Generator must be stopped with Stop unless it has been
+// drained, and must not be used from multiple goroutines at once.
+type Generator[T any] struct {
+	resume func(struct{}) (T, bool)
+	cancel func()
+	done   bool
+}
+
+// New returns a generator that runs fn as a coroutine, producing the
+// values fn passes to yield. The coroutine starts on the first call
+// to Next. Options are passed through to coro.New.
+//
+// If the generator is stopped before fn returns, the pending call to
+// yield panics with coro.ErrCanceled so that fn's deferred calls run.
+// fn should not recover that panic.
+func New[T any](fn func(yield func(T)), opts ...coro.Option) *Generator[T] {
+	resume, cancel := coro.New(func(yield func(T) struct{}, _ func() struct{}) T {
+		fn(func(v T) { yield(v) })
+		var zero T
+		return zero
+	}, opts...)
+	return &Generator[T]{resume: resume, cancel: cancel}
+}
+
+// Of returns a generator that produces values in order.
+func Of[T any](values ...T) *Generator[T] {
+	return New(func(yield func(T)) {
+		for _, v := range values {
+			yield(v)
+		}
+	})
+}
+
+// Next returns the next value and true, or the zero value and false
+// once the generator is exhausted or stopped. If the generator's
+// function panics, Next propagates the panic.
+func (g *Generator[T]) Next() (T, bool) {
+	var zero T
+	if g.done {
+		return zero, false
+	}
+	v, ok := g.resume(struct{}{})
+	if !ok {
+		g.done = true
+		return zero, false
+	}
+	return v, true
+}
+
+// Stop ends the generator early, canceling its coroutine and, through
+// it, any upstream generators. It is safe to call Stop more than once
+// and after the generator is exhausted.
+func (g *Generator[T]) Stop() {
+	if g.done {
+		return
+	}
+	g.done = true
+	panics.Cancel(g.cancel)
+}
+
+// All returns an iterator over the remaining values, for use with a
+// range loop. The generator is stopped when the loop ends, including
+// when it ends early.
+func (g *Generator[T]) All() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		defer g.Stop()
+		for {
+			v, ok := g.Next()
+			if !ok || !yield(v) {
+				return
+			}
+		}
+	}
+}
+
+// Collect drains g and returns its remaining values.
+func Collect[T any](g *Generator[T]) []T {
+	var values []T
+	for v := range g.All() {
+		values = append(values, v)
+	}
+	return values
+}
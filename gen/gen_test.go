@@ -0,0 +1,126 @@
+package gen
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/webriots/coro"
+)
+
+// counter returns an infinite generator of 0, 1, 2, ... that sets
+// *stopped when its coroutine unwinds.
+func counter(stopped *bool) *Generator[int] {
+	return New(func(yield func(int)) {
+		defer func() { *stopped = true }()
+		for i := 0; ; i++ {
+			yield(i)
+		}
+	})
+}
+
+func TestGeneratorNext(t *testing.T) {
+	g := Of(1, 2)
+
+	for _, expected := range []int{1, 2} {
+		v, ok := g.Next()
+		if !ok || v != expected {
+			t.Errorf("Expected (%d, true), got (%d, %t)", expected, v, ok)
+		}
+	}
+	for range 2 {
+		if v, ok := g.Next(); ok || v != 0 {
+			t.Errorf("Expected (0, false), got (%d, %t)", v, ok)
+		}
+	}
+	g.Stop()
+}
+
+func TestGeneratorStop(t *testing.T) {
+	var stopped bool
+	g := counter(&stopped)
+
+	if v, _ := g.Next(); v != 0 {
+		t.Errorf("Expected 0, got %d", v)
+	}
+	g.Stop()
+	g.Stop()
+
+	if !stopped {
+		t.Error("Expected generator to be stopped")
+	}
+	if _, ok := g.Next(); ok {
+		t.Error("Expected stopped generator to be exhausted")
+	}
+}
+
+func TestGeneratorStopBeforeStart(t *testing.T) {
+	g := New(func(yield func(int)) {
+		t.Error("generator should not start")
+	})
+	g.Stop()
+}
+
+func TestGeneratorAll(t *testing.T) {
+	var stopped bool
+	var values []int
+	for v := range counter(&stopped).All() {
+		if v == 3 {
+			break
+		}
+		values = append(values, v)
+	}
+
+	if !reflect.DeepEqual(values, []int{0, 1, 2}) {
+		t.Errorf("Expected [0 1 2], got %v", values)
+	}
+	if !stopped {
+		t.Error("Expected generator to be stopped when the loop breaks")
+	}
+}
+
+func TestGeneratorPanic(t *testing.T) {
+	g := New(func(yield func(int)) {
+		yield(1)
+		panic("test panic")
+	})
+	defer g.Stop()
+
+	g.Next()
+	defer func() {
+		r := recover()
+		if r == nil {
+			t.Fatal("Expected panic but got none")
+		}
+		if err, ok := r.(error); !ok || err.Error() != "test panic" {
+			t.Errorf("Expected panic 'test panic', got '%v'", r)
+		}
+	}()
+	g.Next()
+}
+
+func TestGeneratorStopPanic(t *testing.T) {
+	g := New(func(yield func(int)) {
+		defer func() { panic("cleanup failed") }()
+		yield(1)
+	})
+
+	g.Next()
+	defer func() {
+		r := recover()
+		if err, ok := r.(error); !ok || err.Error() != "cleanup failed" || errors.Is(err, coro.ErrCanceled) {
+			t.Errorf("Expected panic 'cleanup failed', got '%v'", r)
+		}
+	}()
+	g.Stop()
+}
+
+func TestGeneratorOptions(t *testing.T) {
+	var m coro.Metrics
+	g := New(func(yield func(int)) { yield(1) }, coro.WithMetrics(&m))
+	Collect(g)
+
+	if s := m.Stats(); s.Created != 1 || s.Completed != 1 {
+		t.Errorf("Expected one completed coroutine, got %+v", s)
+	}
+}
@@ -0,0 +1,14 @@
+// Package gen provides lazily evaluated generators backed by
+// coroutines, together with combinators such as Map, Filter, Take and
+// Zip for composing them.
+//
+// A generator is created with New from a function that produces
+// values by calling yield. Values are computed only when the consumer
+// asks for them with Next, so generators may be infinite.
+//
+// Every combinator owns the generators it is built from. When a
+// downstream generator stops early, whether because the consumer
+// called Stop or because a combinator such as Take needs no more
+// values, it stops its upstream generators too, so that their
+// coroutines are canceled and their deferred cleanup runs.
+package gen
@@ -0,0 +1,51 @@
+package gen
+
+import "github.com/webriots/coro"
+
+// Rec is a step of a recursive generator. It produces values with
+// y.Yield and recurses into nested steps with y.From.
+type Rec[T any] func(y *Yielder[T])
+
+// Yielder produces the values of a recursive generator.
+type Yielder[T any] struct {
+	yield func(T)
+}
+
+// Yield produces v from the generator.
+func (y *Yielder[T]) Yield(v T) {
+	y.yield(v)
+}
+
+// From produces all of the values of the nested step r, in place.
+func (y *Yielder[T]) From(r Rec[T]) {
+	r(y)
+}
+
+// Recursive returns a generator that produces the values of the
+// recursive step r. Options are passed through to coro.New.
+//
+// Building a recursive generator from one generator per level, each
+// delegating to the next, costs a coroutine switch per level for
+// every value, so producing the values of a tree takes time
+// proportional to their total depth. Recursive instead runs every
+// nested step as a plain function call on the stack of a single
+// coroutine, so each value costs a constant number of switches no
+// matter how deep it is produced:
+//
+//	func walk(n *Node) gen.Rec[int] {
+//		return func(y *gen.Yielder[int]) {
+//			if n == nil {
+//				return
+//			}
+//			y.From(walk(n.Left))
+//			y.Yield(n.Value)
+//			y.From(walk(n.Right))
+//		}
+//	}
+//
+//	values := gen.Recursive(walk(root))
+func Recursive[T any](r Rec[T], opts ...coro.Option) *Generator[T] {
+	return New(func(yield func(T)) {
+		r(&Yielder[T]{yield: yield})
+	}, opts...)
+}
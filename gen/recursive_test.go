@@ -0,0 +1,117 @@
+package gen
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/webriots/coro"
+)
+
+type node struct {
+	left, right *node
+	value       int
+}
+
+// balanced returns a balanced tree holding the values [lo, hi).
+func balanced(lo, hi int) *node {
+	if lo >= hi {
+		return nil
+	}
+	mid := (lo + hi) / 2
+	return &node{left: balanced(lo, mid), value: mid, right: balanced(mid+1, hi)}
+}
+
+// spine returns a tree of depth n in which every node is a right
+// child, holding the values [0, n).
+func spine(n int) *node {
+	var root *node
+	for i := n - 1; i >= 0; i-- {
+		root = &node{value: i, right: root}
+	}
+	return root
+}
+
+func walkRec(n *node) Rec[int] {
+	return func(y *Yielder[int]) {
+		if n == nil {
+			return
+		}
+		y.From(walkRec(n.left))
+		y.Yield(n.value)
+		y.From(walkRec(n.right))
+	}
+}
+
+// walkNested walks a tree with one coroutine per level.
+func walkNested(n *node) func(func(int) struct{}, func() struct{}) int {
+	return func(yield func(int) struct{}, suspend func() struct{}) int {
+		if n == nil {
+			return 0
+		}
+		coro.YieldFrom(yield, suspend, struct{}{}, walkNested(n.left))
+		yield(n.value)
+		coro.YieldFrom(yield, suspend, struct{}{}, walkNested(n.right))
+		return 0
+	}
+}
+
+func TestRecursive(t *testing.T) {
+	values := Collect(Recursive(walkRec(balanced(0, 7))))
+	if expected := []int{0, 1, 2, 3, 4, 5, 6}; !reflect.DeepEqual(values, expected) {
+		t.Errorf("Expected %v, got %v", expected, values)
+	}
+
+	values = Collect(Recursive(walkRec(spine(10000))))
+	if len(values) != 10000 || values[9999] != 9999 {
+		t.Errorf("Expected 10000 values from a deep tree, got %d", len(values))
+	}
+}
+
+func TestRecursiveStop(t *testing.T) {
+	var unwound int
+	var rec func(depth int) Rec[int]
+	rec = func(depth int) Rec[int] {
+		return func(y *Yielder[int]) {
+			defer func() { unwound++ }()
+			y.Yield(depth)
+			y.From(rec(depth + 1))
+		}
+	}
+
+	g := Recursive(rec(0))
+	for range 3 {
+		g.Next()
+	}
+	g.Stop()
+	if unwound != 3 {
+		t.Errorf("Expected all 3 levels to unwind, got %d", unwound)
+	}
+}
+
+func benchmarkRecursive(b *testing.B, root *node) {
+	for range b.N {
+		g := Recursive(walkRec(root))
+		for {
+			if _, ok := g.Next(); !ok {
+				break
+			}
+		}
+	}
+}
+
+func benchmarkNested(b *testing.B, root *node) {
+	for range b.N {
+		resume, cancel := coro.New(walkNested(root))
+		for {
+			if _, running := resume(struct{}{}); !running {
+				break
+			}
+		}
+		cancel()
+	}
+}
+
+func BenchmarkRecursiveBalanced(b *testing.B) { benchmarkRecursive(b, balanced(0, 1<<12)) }
+func BenchmarkNestedBalanced(b *testing.B)    { benchmarkNested(b, balanced(0, 1<<12)) }
+func BenchmarkRecursiveSpine(b *testing.B)    { benchmarkRecursive(b, spine(512)) }
+func BenchmarkNestedSpine(b *testing.B)       { benchmarkNested(b, spine(512)) }
@@ -0,0 +1,120 @@
+package coro
+
+// Hooks is a set of callbacks invoked at well-defined points in a
+// coroutine's lifecycle. Any field may be nil. Hooks are the extension
+// point for cross-cutting concerns such as metrics, tracing and
+// logging, and allow them to be attached without modifying the
+// coroutine body.
+//
+// BeforeResume and AfterResume run on the caller's side of resume,
+// immediately before control is transferred to the coroutine and
+// immediately after it comes back. They are not called when resume
+// returns early because the coroutine has already finished.
+//
+// OnYield, OnComplete, OnPanic and OnCancel run on the coroutine's
+// side, before control is transferred back to the caller:
+//   - OnYield is called each time the coroutine yields or suspends.
+//   - OnComplete is called when the coroutine function returns.
+//   - OnPanic is called with the wrapped panic value when the
+//     coroutine function panics for any reason other than
+//     cancellation.
+//   - OnCancel is called when a coroutine terminates because cancel
+//     was called, including when cancel is called before the first
+//     resume.
+//
+// Exactly one of OnComplete, OnPanic and OnCancel is called for each
+// coroutine that terminates. Hooks must not panic.
+type Hooks struct {
+	BeforeResume func()
+	AfterResume  func()
+	OnYield      func()
+	OnComplete   func()
+	OnPanic      func(err error)
+	OnCancel     func()
+}
+
+// WithHooks returns an Option that attaches h to a coroutine. It may
+// be given multiple times, in which case the hooks for each event are
+// run in the order the options were given.
+func WithHooks(h Hooks) Option {
+	return func(c *config) {
+		c.hooks = c.hooks.then(h)
+	}
+}
+
+// then returns hooks that run h's callbacks followed by next's.
+func (h Hooks) then(next Hooks) Hooks {
+	return Hooks{
+		BeforeResume: chain(h.BeforeResume, next.BeforeResume),
+		AfterResume:  chain(h.AfterResume, next.AfterResume),
+		OnYield:      chain(h.OnYield, next.OnYield),
+		OnComplete:   chain(h.OnComplete, next.OnComplete),
+		OnPanic:      chainErr(h.OnPanic, next.OnPanic),
+		OnCancel:     chain(h.OnCancel, next.OnCancel),
+	}
+}
+
+// chain returns a function that calls a and then b, skipping either
+// one if it is nil.
+func chain(a, b func()) func() {
+	switch {
+	case a == nil:
+		return b
+	case b == nil:
+		return a
+	}
+	return func() {
+		a()
+		b()
+	}
+}
+
+// chainErr is like chain for callbacks that receive an error.
+func chainErr(a, b func(error)) func(error) {
+	switch {
+	case a == nil:
+		return b
+	case b == nil:
+		return a
+	}
+	return func(err error) {
+		a(err)
+		b(err)
+	}
+}
+
+func (c *config) beforeResume() {
+	if h := c.hooks.BeforeResume; h != nil {
+		h()
+	}
+}
+
+func (c *config) afterResume() {
+	if h := c.hooks.AfterResume; h != nil {
+		h()
+	}
+}
+
+func (c *config) onYield() {
+	if h := c.hooks.OnYield; h != nil {
+		h()
+	}
+}
+
+func (c *config) onComplete() {
+	if h := c.hooks.OnComplete; h != nil {
+		h()
+	}
+}
+
+func (c *config) onPanic(err error) {
+	if h := c.hooks.OnPanic; h != nil {
+		h(err)
+	}
+}
+
+func (c *config) onCancel() {
+	if h := c.hooks.OnCancel; h != nil {
+		h()
+	}
+}
@@ -0,0 +1,161 @@
+package coro
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// recordHooks returns hooks that append the name of each event to
+// events.
+func recordHooks(events *[]string) Hooks {
+	return Hooks{
+		BeforeResume: func() { *events = append(*events, "before") },
+		AfterResume:  func() { *events = append(*events, "after") },
+		OnYield:      func() { *events = append(*events, "yield") },
+		OnComplete:   func() { *events = append(*events, "complete") },
+		OnPanic:      func(error) { *events = append(*events, "panic") },
+		OnCancel:     func() { *events = append(*events, "cancel") },
+	}
+}
+
+func TestHooksComplete(t *testing.T) {
+	var events []string
+	resume, cancel := New(func(yield func(string) int, suspend func() int) string {
+		yield("first")
+		suspend()
+		return "done"
+	}, WithHooks(recordHooks(&events)))
+	defer cancel()
+
+	for {
+		if _, running := resume(0); !running {
+			break
+		}
+	}
+	resume(0)
+
+	expected := []string{
+		"before", "yield", "after",
+		"before", "yield", "after",
+		"before", "complete", "after",
+	}
+	if !reflect.DeepEqual(events, expected) {
+		t.Errorf("Expected events %v, got %v", expected, events)
+	}
+}
+
+func TestHooksPanic(t *testing.T) {
+	var (
+		events []string
+		perr   error
+	)
+	hooks := recordHooks(&events)
+	hooks.OnPanic = func(err error) {
+		events = append(events, "panic")
+		perr = err
+	}
+
+	resume, cancel := New(func(yield func(string) int, suspend func() int) string {
+		panic("test panic")
+	}, WithHooks(hooks))
+	defer cancel()
+
+	func() {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Error("Expected panic but got none")
+			}
+		}()
+		resume(0)
+	}()
+
+	expected := []string{"before", "panic", "after"}
+	if !reflect.DeepEqual(events, expected) {
+		t.Errorf("Expected events %v, got %v", expected, events)
+	}
+	if perr == nil || perr.Error() != "test panic" {
+		t.Errorf("Expected OnPanic error 'test panic', got '%v'", perr)
+	}
+}
+
+func TestHooksCancel(t *testing.T) {
+	var events []string
+	resume, cancel := New(func(yield func(string) int, suspend func() int) string {
+		defer func() {
+			if p := recover(); !errors.Is(p.(error), ErrCanceled) {
+				t.Errorf("Expected ErrCanceled, got '%v'", p)
+			}
+		}()
+		yield("first")
+		return "done"
+	}, WithHooks(recordHooks(&events)))
+
+	resume(0)
+	cancel()
+	cancel()
+
+	expected := []string{"before", "yield", "after", "cancel"}
+	if !reflect.DeepEqual(events, expected) {
+		t.Errorf("Expected events %v, got %v", expected, events)
+	}
+}
+
+func TestHooksCancelBeforeResume(t *testing.T) {
+	var events []string
+	_, cancel := New(func(yield func(string) int, suspend func() int) string {
+		t.Error("coroutine should not start")
+		return ""
+	}, WithHooks(recordHooks(&events)))
+
+	cancel()
+
+	expected := []string{"cancel"}
+	if !reflect.DeepEqual(events, expected) {
+		t.Errorf("Expected events %v, got %v", expected, events)
+	}
+}
+
+func TestHooksPanicDuringCancel(t *testing.T) {
+	var events []string
+	resume, cancel := New(func(yield func(string) int, suspend func() int) string {
+		defer func() { panic("deferred error") }()
+		yield("first")
+		return "done"
+	}, WithHooks(recordHooks(&events)))
+
+	resume(0)
+	func() {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Error("Expected panic but got none")
+			}
+		}()
+		cancel()
+	}()
+
+	expected := []string{"before", "yield", "after", "panic"}
+	if !reflect.DeepEqual(events, expected) {
+		t.Errorf("Expected events %v, got %v", expected, events)
+	}
+}
+
+func TestHooksOrder(t *testing.T) {
+	var events []string
+	resume, cancel := New(func(yield func(string) int, suspend func() int) string {
+		return "done"
+	},
+		WithHooks(Hooks{OnComplete: func() { events = append(events, "first") }}),
+		WithHooks(Hooks{}),
+		nil,
+		WithHooks(Hooks{OnComplete: func() { events = append(events, "second") }}),
+	)
+	defer cancel()
+
+	resume(0)
+
+	expected := []string{"first", "second"}
+	if !reflect.DeepEqual(events, expected) {
+		t.Errorf("Expected events %v, got %v", expected, events)
+	}
+}
@@ -0,0 +1,41 @@
+// Package panics handles the panics recovered by the packages of this
+// module, and in particular the panic with which a canceled coroutine
+// unwinds.
+package panics
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrCanceled is the error of a canceled coroutine. It is defined here,
+// rather than in package coro, so that it can be used by packages coro
+// itself depends on, and is exported from coro as coro.ErrCanceled.
+var ErrCanceled = errors.New("coro: coroutine canceled")
+
+// AsError converts a recovered panic value into an error.
+func AsError(p any) error {
+	if err, ok := p.(error); ok {
+		return err
+	}
+	return fmt.Errorf("%v", p)
+}
+
+// IsCanceled reports whether a recovered panic value is the
+// cancellation of a coroutine.
+func IsCanceled(p any) bool {
+	err, ok := p.(error)
+	return ok && errors.Is(err, ErrCanceled)
+}
+
+// Cancel calls cancel, absorbing the cancellation panic that unwinds a
+// coroutine which does not recover from it. Other panics raised while
+// the coroutine unwinds are propagated.
+func Cancel(cancel func()) {
+	defer func() {
+		if p := recover(); p != nil && !IsCanceled(p) {
+			panic(p)
+		}
+	}()
+	cancel()
+}
@@ -0,0 +1,29 @@
+package panics
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestAsError(t *testing.T) {
+	err := errors.New("boom")
+	if AsError(err) != err {
+		t.Errorf("Expected the error itself, got %v", AsError(err))
+	}
+	if got := AsError(42).Error(); got != "42" {
+		t.Errorf("Expected '42', got '%s'", got)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	Cancel(func() { panic(fmt.Errorf("unwinding: %w", ErrCanceled)) })
+	Cancel(func() {})
+
+	defer func() {
+		if p := recover(); p != "cleanup failed" {
+			t.Errorf("Expected other panics to propagate, got %v", p)
+		}
+	}()
+	Cancel(func() { panic("cleanup failed") })
+}
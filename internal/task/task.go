@@ -0,0 +1,119 @@
+// Package task implements the coroutine tasks shared by the schedulers
+// of this module: coroutines that are resumed one step at a time, park
+// until they are woken, and unwind when they are canceled. Schedulers
+// keep their own queues of runnable tasks.
+package task
+
+import (
+	"github.com/webriots/coro"
+	"github.com/webriots/coro/internal/panics"
+)
+
+// State is the scheduling state of a task.
+type State int
+
+const (
+	Runnable State = iota
+	Running
+	Parked
+	Done
+)
+
+// Task is a coroutine run by a scheduler. A Task must only be used
+// from one goroutine at a time.
+type Task struct {
+	State   State
+	woken   bool
+	resume  func(struct{}) (struct{}, bool)
+	cancel  func()
+	suspend func() struct{}
+}
+
+// Start creates the task's coroutine, which calls fn when it is first
+// resumed. Options are passed through to coro.New.
+func (t *Task) Start(fn func(), opts []coro.Option) {
+	t.resume, t.cancel = coro.New(func(_ func(struct{}) struct{}, suspend func() struct{}) struct{} {
+		t.suspend = suspend
+		fn()
+		return struct{}{}
+	}, opts...)
+}
+
+// Step runs t until it suspends or finishes, and reports whether it
+// finished, with the error of its panic if it panicked.
+func (t *Task) Step() (finished bool, err error) {
+	t.State = Running
+	return Step(t.resume)
+}
+
+// Suspend suspends t, which is already queued or parked. It must only
+// be called by t itself.
+func (t *Task) Suspend() {
+	t.suspend()
+}
+
+// Yield marks t runnable and suspends it. The scheduler must queue t
+// first. It must only be called by t itself.
+func (t *Task) Yield() {
+	t.State = Runnable
+	t.suspend()
+}
+
+// Park suspends t until it is woken by Wake. If t was woken since it
+// last parked, Park returns immediately. It must only be called by t
+// itself.
+func (t *Task) Park() {
+	if t.woken {
+		t.woken = false
+		return
+	}
+	t.State = Parked
+	t.suspend()
+}
+
+// Wake makes a parked task runnable again, and reports whether it did,
+// in which case the scheduler must queue it. Waking a task that is not
+// parked makes its next Park return immediately, so wakeups are not
+// lost; waking a finished task does nothing.
+func (t *Task) Wake() bool {
+	switch t.State {
+	case Parked:
+		t.State = Runnable
+		return true
+	case Runnable, Running:
+		t.woken = true
+	}
+	return false
+}
+
+// Unwind cancels t's coroutine, returning the error t finishes with:
+// canceled, or the error of a panic raised while it unwound.
+func (t *Task) Unwind(canceled error) error {
+	return Unwind(t.cancel, canceled)
+}
+
+// Step resumes a task's coroutine once, and reports whether it
+// finished, converting a panic into its error.
+func Step(resume func(struct{}) (struct{}, bool)) (finished bool, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			finished, err = true, panics.AsError(p)
+		}
+	}()
+	_, running := resume(struct{}{})
+	return !running, nil
+}
+
+// Unwind calls a task's cancel function, returning canceled, or the
+// error of a panic other than the cancellation raised while the task
+// unwound.
+func Unwind(cancel func(), canceled error) (err error) {
+	err = canceled
+	defer func() {
+		if p := recover(); p != nil && !panics.IsCanceled(p) {
+			err = panics.AsError(p)
+		}
+	}()
+	cancel()
+	return err
+}
@@ -0,0 +1,155 @@
+package coro
+
+import (
+	"context"
+	"reflect"
+	"runtime/pprof"
+	"sync"
+	"unsafe"
+)
+
+// nameLabel is the profiler label key that carries the name of a
+// coroutine created with WithName.
+const nameLabel = "coro"
+
+//go:linkname getProfLabel runtime/pprof.runtime_getProfLabel
+func getProfLabel() unsafe.Pointer
+
+//go:linkname setProfLabel runtime/pprof.runtime_setProfLabel
+func setProfLabel(unsafe.Pointer)
+
+// Profiler labels are carried by goroutines, and a coroutine runs on
+// a goroutine of its own, so without intervention its samples would
+// be attributed to the labels in effect when it was created. Instead,
+// each resume captures the labels of the resuming goroutine and the
+// coroutine adopts them until it next yields, so `go tool pprof
+// -tagfocus` attributes coroutine work to whoever resumed it.
+//
+// Coroutines created with WithName additionally carry a "coro" label
+// with their name. Merging it into the resumer's labels requires a
+// context holding the raw label set, which the pprof package only
+// exposes through its unexported context key, so labelKey and
+// labelType are discovered once by probing the pprof API. Building an
+// interface value from labelType and a raw label set is only sound
+// while pprof stores a pointer under that key, and the goroutine
+// carries that same pointer, so probeLabels verifies both before
+// enabling the merge. Otherwise named coroutines fall back to a label
+// set built with the public API, holding only their name.
+var (
+	labelOnce sync.Once
+	labelKey  any
+	labelType unsafe.Pointer
+)
+
+// eface is the runtime representation of an empty interface.
+type eface struct {
+	typ  unsafe.Pointer
+	data unsafe.Pointer
+}
+
+// keyProbe is a context that remembers the key of the first value
+// looked up in it.
+type keyProbe struct {
+	context.Context
+	key any
+}
+
+func (p *keyProbe) Value(key any) any {
+	if p.key == nil {
+		p.key = key
+	}
+	return nil
+}
+
+// labelContext is a context that carries a raw label set as captured
+// from a goroutine.
+type labelContext struct {
+	context.Context
+	labels unsafe.Pointer
+}
+
+func (c labelContext) Value(key any) any {
+	if key != labelKey || c.labels == nil {
+		return c.Context.Value(key)
+	}
+	var v any
+	e := (*eface)(unsafe.Pointer(&v))
+	e.typ, e.data = labelType, c.labels
+	return v
+}
+
+// probeLabels discovers pprof's context key and label set type, and
+// leaves labelKey nil unless it can verify that the label set is
+// stored as a pointer and is what the goroutine carries once the
+// context's labels are set.
+func probeLabels() {
+	probe := &keyProbe{Context: context.Background()}
+	pprof.Label(probe, nameLabel)
+	ctx := pprof.WithLabels(context.Background(), pprof.Labels(nameLabel, ""))
+	v := ctx.Value(probe.key)
+	if v == nil || reflect.TypeOf(v).Kind() != reflect.Pointer {
+		return
+	}
+
+	saved := getProfLabel()
+	pprof.SetGoroutineLabels(ctx)
+	carried := getProfLabel()
+	setProfLabel(saved)
+	e := (*eface)(unsafe.Pointer(&v))
+	if carried != e.data {
+		return
+	}
+	labelKey, labelType = probe.key, e.typ
+}
+
+// rawLabels returns the raw label set carried by ctx.
+func rawLabels(ctx context.Context) unsafe.Pointer {
+	v := ctx.Value(labelKey)
+	return (*eface)(unsafe.Pointer(&v)).data
+}
+
+// captureLabels records the profiler labels of the goroutine that is
+// about to switch into the coroutine.
+func (c *config) captureLabels() {
+	c.labels = getProfLabel()
+}
+
+// applyLabels gives the coroutine's goroutine the labels captured by
+// the most recent resume or cancel, plus its name if it has one. An
+// unnamed coroutine resumed by goroutines without labels is left
+// alone, since it has none to clear.
+func (c *config) applyLabels() {
+	if c.name == "" {
+		if c.labels != nil || c.labeled {
+			setProfLabel(c.labels)
+			c.labeled = c.labels != nil
+		}
+		return
+	}
+	if c.named == nil || c.labels != c.namedFrom {
+		c.namedFrom, c.named = c.labels, c.withName(c.labels)
+	}
+	setProfLabel(c.named)
+}
+
+// withName returns a raw label set that adds the coroutine's name to
+// labels. If the pprof package could not be probed, it returns a label
+// set holding only the name, built with the public API.
+func (c *config) withName(labels unsafe.Pointer) unsafe.Pointer {
+	labelOnce.Do(probeLabels)
+	if labelKey == nil {
+		return c.nameOnly()
+	}
+	ctx := labelContext{Context: context.Background(), labels: labels}
+	return rawLabels(pprof.WithLabels(ctx, pprof.Labels(nameLabel, c.name)))
+}
+
+// nameOnly returns a raw label set holding just the coroutine's name,
+// by setting it on the current goroutine with pprof.SetGoroutineLabels
+// and reading it back.
+func (c *config) nameOnly() unsafe.Pointer {
+	saved := getProfLabel()
+	defer setProfLabel(saved)
+	pprof.SetGoroutineLabels(pprof.WithLabels(context.Background(), pprof.Labels(nameLabel, c.name)))
+	return getProfLabel()
+}
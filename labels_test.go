@@ -0,0 +1,109 @@
+package coro
+
+import (
+	"context"
+	"runtime/pprof"
+	"testing"
+	"unsafe"
+)
+
+// currentLabels returns a context carrying the calling goroutine's
+// profiler labels.
+func currentLabels() context.Context {
+	labelOnce.Do(probeLabels)
+	return labelContext{Context: context.Background(), labels: getProfLabel()}
+}
+
+func TestLabelsPropagated(t *testing.T) {
+	defer pprof.SetGoroutineLabels(context.Background())
+
+	resume, cancel := New(func(yield func(string) int, suspend func() int) string {
+		for {
+			v, _ := pprof.Label(currentLabels(), "request")
+			yield(v)
+		}
+	})
+	defer func() {
+		defer func() { _ = recover() }()
+		cancel()
+	}()
+
+	for _, request := range []string{"a", "b"} {
+		pprof.SetGoroutineLabels(pprof.WithLabels(context.Background(), pprof.Labels("request", request)))
+		if out, _ := resume(0); out != request {
+			t.Errorf("Expected coroutine to run with label '%s', got '%s'", request, out)
+		}
+	}
+
+	pprof.SetGoroutineLabels(context.Background())
+	if out, _ := resume(0); out != "" {
+		t.Errorf("Expected coroutine to run without labels, got '%s'", out)
+	}
+}
+
+func TestLabelsNamed(t *testing.T) {
+	defer pprof.SetGoroutineLabels(context.Background())
+
+	type labels struct{ name, request string }
+	resume, cancel := New(func(yield func(labels) int, suspend func() int) labels {
+		for {
+			ctx := currentLabels()
+			name, _ := pprof.Label(ctx, nameLabel)
+			request, _ := pprof.Label(ctx, "request")
+			yield(labels{name, request})
+		}
+	}, WithName("tokenizer"))
+	defer func() {
+		defer func() { _ = recover() }()
+		cancel()
+	}()
+
+	if out, _ := resume(0); out != (labels{"tokenizer", ""}) {
+		t.Errorf("Expected only the name label, got %+v", out)
+	}
+
+	pprof.SetGoroutineLabels(pprof.WithLabels(context.Background(), pprof.Labels("request", "a")))
+	for range 2 {
+		if out, _ := resume(0); out != (labels{"tokenizer", "a"}) {
+			t.Errorf("Expected name and request labels, got %+v", out)
+		}
+	}
+
+	if name, ok := pprof.Label(currentLabels(), nameLabel); ok {
+		t.Errorf("Expected resumer not to carry the name label, got '%s'", name)
+	}
+}
+
+func TestLabelsNamedFallback(t *testing.T) {
+	defer pprof.SetGoroutineLabels(context.Background())
+
+	// Without a verified probe, a named coroutine carries only its
+	// name, and the resumer's labels are dropped.
+	labelOnce.Do(probeLabels)
+	key := labelKey
+	labelKey = nil
+	resume, cancel := New(func(yield func(unsafe.Pointer) int, suspend func() int) unsafe.Pointer {
+		for {
+			yield(getProfLabel())
+		}
+	}, WithName("tokenizer"))
+	defer func() {
+		defer func() { _ = recover() }()
+		cancel()
+	}()
+
+	pprof.SetGoroutineLabels(pprof.WithLabels(context.Background(), pprof.Labels("request", "a")))
+	raw, _ := resume(0)
+	labelKey = key
+
+	ctx := labelContext{Context: context.Background(), labels: raw}
+	if name, _ := pprof.Label(ctx, nameLabel); name != "tokenizer" {
+		t.Errorf("Expected the name label, got '%s'", name)
+	}
+	if request, ok := pprof.Label(ctx, "request"); ok {
+		t.Errorf("Expected no request label, got '%s'", request)
+	}
+	if request, _ := pprof.Label(currentLabels(), "request"); request != "a" {
+		t.Errorf("Expected resumer to keep its labels, got '%s'", request)
+	}
+}
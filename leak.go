@@ -0,0 +1,199 @@
+package coro
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// leakTimeout bounds how long VerifyNone waits for the garbage
+	// collector to finalize abandoned coroutines.
+	leakTimeout = time.Second
+	// leakRounds is the number of consecutive garbage collections
+	// that must finalize nothing before VerifyNone stops waiting.
+	leakRounds = 10
+)
+
+// TB is the subset of testing.TB used by VerifyNone and by the helpers
+// of package corotest. Fatalf must stop the calling goroutine, as
+// testing.TB's does.
+type TB interface {
+	Helper()
+	Errorf(format string, args ...any)
+	Fatalf(format string, args ...any)
+}
+
+// Leak describes a coroutine that was never run to completion or
+// canceled.
+type Leak struct {
+	// Collected reports whether the coroutine's resume and cancel
+	// functions were garbage collected. A collected coroutine can
+	// never be resumed or canceled again, so its resources are lost
+	// for the lifetime of the process.
+	Collected bool
+	// Name is the name given to the coroutine with WithName, if any.
+	Name string
+	// Stack is the stack trace of the call to New that created the
+	// coroutine.
+	Stack string
+}
+
+// String returns a description of the leak including the creation
+// stack.
+func (l Leak) String() string {
+	state := "still suspended"
+	if l.Collected {
+		state = "garbage collected while suspended"
+	}
+	name := ""
+	if l.Name != "" {
+		name = fmt.Sprintf(" %q", l.Name)
+	}
+	return fmt.Sprintf("coro: coroutine%s %s, created at:\n%s", name, state, l.Stack)
+}
+
+// leaks is the global leak detection state. enabled is set under the
+// lock, but read without it by New.
+var leaks struct {
+	sync.Mutex
+	enabled atomic.Bool
+	onLeak  func(Leak)
+	live    map[*record]struct{}
+	found   []Leak
+}
+
+// owner is referenced only by a coroutine's resume and cancel
+// functions, never by the coroutine itself, so that it becomes
+// unreachable exactly when the caller abandons the coroutine. The
+// suspended coroutine's own stack is a garbage collection root and
+// would otherwise keep everything it references alive.
+type owner struct {
+	rec *record
+}
+
+// EnableLeakDetection starts tracking coroutines created by New. Each
+// tracked coroutine records its creation stack, and a finalizer
+// reports it if its resume and cancel functions are garbage collected
+// before it terminates. The optional onLeak function is called, from
+// the finalizer goroutine, for each such coroutine; leaks are also
+// retained for VerifyNone.
+//
+// Leak detection adds overhead to New and is intended for tests and
+// debugging. The returned function restores the previous setting.
+func EnableLeakDetection(onLeak func(Leak)) (disable func()) {
+	leaks.Lock()
+	defer leaks.Unlock()
+	enabled, prev := leaks.enabled.Load(), leaks.onLeak
+	leaks.enabled.Store(true)
+	leaks.onLeak = onLeak
+	if leaks.live == nil {
+		leaks.live = make(map[*record]struct{})
+	}
+	return func() {
+		leaks.Lock()
+		defer leaks.Unlock()
+		leaks.enabled.Store(enabled)
+		leaks.onLeak = prev
+	}
+}
+
+// VerifyNone reports an error on t for every tracked coroutine that
+// has not terminated, and for every tracked coroutine that was
+// garbage collected while suspended. It runs the garbage collector
+// to give abandoned coroutines a chance to be found. Reported leaks
+// are forgotten, so each is reported only once.
+//
+// It is typically deferred at the start of a test, after enabling
+// leak detection, for example in TestMain:
+//
+//	func TestMain(m *testing.M) {
+//		coro.EnableLeakDetection(nil)
+//		os.Exit(m.Run())
+//	}
+//
+//	func TestGenerator(t *testing.T) {
+//		defer coro.VerifyNone(t)
+//		...
+//	}
+func VerifyNone(t TB) {
+	t.Helper()
+	for _, l := range collectLeaks() {
+		t.Errorf("%s", l)
+	}
+}
+
+// collectLeaks runs the garbage collector until every abandoned
+// coroutine has been finalized, then returns and forgets all
+// outstanding leaks.
+func collectLeaks() []Leak {
+	deadline := time.Now().Add(leakTimeout)
+	for prev, stable := -1, 0; stable < leakRounds && time.Now().Before(deadline); {
+		runtime.GC()
+		leaks.Lock()
+		n := len(leaks.live)
+		leaks.Unlock()
+		if n == 0 {
+			break
+		}
+		if n == prev {
+			stable++
+		} else {
+			prev, stable = n, 0
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	leaks.Lock()
+	defer leaks.Unlock()
+	found := leaks.found
+	for rec := range leaks.live {
+		found = append(found, Leak{Name: rec.name, Stack: rec.stack()})
+		delete(leaks.live, rec)
+	}
+	leaks.found = nil
+	return found
+}
+
+// trackLeak registers rec for leak detection if it is enabled. It
+// returns the owner that must be kept alive by the coroutine's resume
+// and cancel functions, or nil.
+func trackLeak(rec *record) *owner {
+	leaks.Lock()
+	defer leaks.Unlock()
+	if !leaks.enabled.Load() {
+		return nil
+	}
+	leaks.live[rec] = struct{}{}
+	o := &owner{rec: rec}
+	runtime.SetFinalizer(o, func(o *owner) { o.rec.collected() })
+	return o
+}
+
+// untrackLeak forgets a coroutine that terminated.
+func untrackLeak(rec *record) {
+	leaks.Lock()
+	defer leaks.Unlock()
+	delete(leaks.live, rec)
+}
+
+// collected reports a coroutine whose owner was garbage collected if
+// it had not terminated.
+func (r *record) collected() {
+	leaks.Lock()
+	if _, ok := leaks.live[r]; !ok {
+		leaks.Unlock()
+		return
+	}
+	delete(leaks.live, r)
+	l := Leak{Collected: true, Name: r.name, Stack: r.stack()}
+	leaks.found = append(leaks.found, l)
+	onLeak := leaks.onLeak
+	leaks.Unlock()
+
+	if onLeak != nil {
+		onLeak(l)
+	}
+}
@@ -0,0 +1,140 @@
+package coro
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeTB records the errors reported through the TB interface.
+type fakeTB struct {
+	errors []string
+}
+
+func (f *fakeTB) Helper() {}
+
+func (f *fakeTB) Errorf(format string, args ...any) {
+	f.errors = append(f.errors, fmt.Sprintf(format, args...))
+}
+
+func (f *fakeTB) Fatalf(format string, args ...any) {
+	f.Errorf(format, args...)
+	runtime.Goexit()
+}
+
+// abandonGenerator starts a generator and drops it mid-iteration
+// without calling cancel.
+func abandonGenerator() {
+	resume, _ := New(func(yield func(int) struct{}, suspend func() struct{}) int {
+		for i := 0; ; i++ {
+			yield(i)
+		}
+	})
+	resume(struct{}{})
+}
+
+func TestLeakDetectionCollected(t *testing.T) {
+	reported := make(chan Leak, 1)
+	disable := EnableLeakDetection(func(l Leak) { reported <- l })
+	defer disable()
+
+	abandonGenerator()
+
+	var tb fakeTB
+	VerifyNone(&tb)
+	if len(tb.errors) != 1 {
+		t.Fatalf("Expected 1 leak, got %d: %v", len(tb.errors), tb.errors)
+	}
+	if !strings.Contains(tb.errors[0], "garbage collected while suspended") {
+		t.Errorf("Expected collected leak, got '%s'", tb.errors[0])
+	}
+	if !strings.Contains(tb.errors[0], "abandonGenerator") {
+		t.Errorf("Expected creation stack to contain 'abandonGenerator', got '%s'", tb.errors[0])
+	}
+	select {
+	case l := <-reported:
+		if !l.Collected {
+			t.Errorf("Expected onLeak to report a collected leak, got %v", l)
+		}
+	case <-time.After(leakTimeout):
+		t.Error("Expected onLeak to be called")
+	}
+
+	tb = fakeTB{}
+	VerifyNone(&tb)
+	if len(tb.errors) != 0 {
+		t.Errorf("Expected leaks to be reported once, got %v", tb.errors)
+	}
+}
+
+func TestLeakDetectionLive(t *testing.T) {
+	disable := EnableLeakDetection(nil)
+	defer disable()
+
+	resume, cancel := New(func(yield func(int) struct{}, suspend func() struct{}) int {
+		defer func() { _ = recover() }()
+		suspend()
+		return 0
+	})
+	resume(struct{}{})
+
+	var tb fakeTB
+	VerifyNone(&tb)
+	if len(tb.errors) != 1 || !strings.Contains(tb.errors[0], "still suspended") {
+		t.Errorf("Expected a live leak, got %v", tb.errors)
+	}
+
+	cancel()
+}
+
+func TestLeakDetectionNone(t *testing.T) {
+	disable := EnableLeakDetection(nil)
+	defer disable()
+	defer VerifyNone(t)
+
+	// Completed.
+	resume, cancel := New(func(yield func(int) struct{}, suspend func() struct{}) int {
+		yield(1)
+		return 2
+	})
+	resume(struct{}{})
+	resume(struct{}{})
+	cancel()
+
+	// Canceled.
+	resume, cancel = New(func(yield func(int) struct{}, suspend func() struct{}) int {
+		defer func() { _ = recover() }()
+		yield(1)
+		return 2
+	})
+	resume(struct{}{})
+	cancel()
+
+	// Never started.
+	_, cancel = New(func(yield func(int) struct{}, suspend func() struct{}) int {
+		return 0
+	})
+	cancel()
+}
+
+func TestLeakDetectionDisabled(t *testing.T) {
+	EnableLeakDetection(nil)()
+
+	abandonGenerator()
+
+	var tb fakeTB
+	VerifyNone(&tb)
+	if len(tb.errors) != 0 {
+		t.Errorf("Expected no leaks to be tracked, got %v", tb.errors)
+	}
+}
+
+func TestLeakString(t *testing.T) {
+	s := Leak{Collected: true, Name: "tokenizer", Stack: "main.main\n"}.String()
+	expected := "coro: coroutine \"tokenizer\" garbage collected while suspended, created at:\nmain.main\n"
+	if s != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, s)
+	}
+}
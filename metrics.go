@@ -0,0 +1,181 @@
+package coro
+
+import (
+	"expvar"
+	"math/bits"
+	"sync/atomic"
+	"time"
+)
+
+// histogramBuckets is the number of bounded buckets in a resume
+// latency histogram. Bucket i counts durations below minBucket << i,
+// and one extra bucket counts everything slower than the last bound.
+const histogramBuckets = 24
+
+// minBucket is the upper bound of the fastest histogram bucket.
+const minBucket = 128 * time.Nanosecond
+
+// Metrics collects counters and a resume latency histogram for the
+// coroutines it is attached to with WithMetrics. A single Metrics
+// value is typically shared by many coroutines, and is safe for
+// concurrent use. The zero value is ready to use.
+type Metrics struct {
+	created   atomic.Int64
+	completed atomic.Int64
+	canceled  atomic.Int64
+	panicked  atomic.Int64
+	resumes   atomic.Int64
+	latency   [histogramBuckets + 1]atomic.Int64
+	total     atomic.Int64
+}
+
+// Stats is a point-in-time snapshot of a Metrics value.
+type Stats struct {
+	// Created is the number of coroutines created.
+	Created int64
+	// Completed is the number of coroutines whose function returned.
+	Completed int64
+	// Canceled is the number of coroutines terminated by cancel.
+	Canceled int64
+	// Panicked is the number of coroutines terminated by a panic.
+	Panicked int64
+	// Live is the number of coroutines that have been created but
+	// have not yet terminated. A value that keeps growing usually
+	// means coroutines are being abandoned without calling cancel.
+	Live int64
+	// Resumes is the total number of times control was transferred
+	// into a coroutine by resume.
+	Resumes int64
+	// ResumeLatency is the distribution of time spent inside each
+	// resume, from the switch into the coroutine until control came
+	// back to the caller.
+	ResumeLatency Histogram
+}
+
+// Histogram is a snapshot of a duration distribution with
+// exponentially sized buckets.
+type Histogram struct {
+	// Bounds holds the exclusive upper bound of each bucket.
+	Bounds []time.Duration
+	// Counts holds the number of observations in each bucket. It has
+	// one more element than Bounds, counting observations at or above
+	// the last bound.
+	Counts []int64
+	// Count is the total number of observations.
+	Count int64
+	// Sum is the total of all observed durations.
+	Sum time.Duration
+}
+
+// WithMetrics returns an Option that records the coroutine's
+// lifecycle events and resume latencies in m.
+func WithMetrics(m *Metrics) Option {
+	return func(c *config) {
+		m.created.Add(1)
+		var start time.Time
+		c.hooks = c.hooks.then(Hooks{
+			BeforeResume: func() {
+				start = time.Now()
+			},
+			AfterResume: func() {
+				m.observe(time.Since(start))
+			},
+			OnComplete: func() {
+				m.completed.Add(1)
+			},
+			OnPanic: func(error) {
+				m.panicked.Add(1)
+			},
+			OnCancel: func() {
+				m.canceled.Add(1)
+			},
+		})
+	}
+}
+
+// observe records a single resume that took d.
+func (m *Metrics) observe(d time.Duration) {
+	m.resumes.Add(1)
+	m.total.Add(int64(d))
+	m.latency[bucket(d)].Add(1)
+}
+
+// bucket returns the index of the histogram bucket that counts d.
+func bucket(d time.Duration) int {
+	if d < 0 {
+		d = 0
+	}
+	i := bits.Len64(uint64(d / minBucket))
+	if i > histogramBuckets {
+		i = histogramBuckets
+	}
+	return i
+}
+
+// Stats returns a snapshot of the metrics collected so far. The
+// counters are read individually, so a snapshot taken while
+// coroutines are running may be slightly inconsistent.
+func (m *Metrics) Stats() Stats {
+	s := Stats{
+		Created:   m.created.Load(),
+		Completed: m.completed.Load(),
+		Canceled:  m.canceled.Load(),
+		Panicked:  m.panicked.Load(),
+		Resumes:   m.resumes.Load(),
+		ResumeLatency: Histogram{
+			Bounds: make([]time.Duration, histogramBuckets),
+			Counts: make([]int64, histogramBuckets+1),
+			Sum:    time.Duration(m.total.Load()),
+		},
+	}
+	s.Live = s.Created - s.Completed - s.Canceled - s.Panicked
+	for i := range s.ResumeLatency.Counts {
+		if i < histogramBuckets {
+			s.ResumeLatency.Bounds[i] = minBucket << i
+		}
+		n := m.latency[i].Load()
+		s.ResumeLatency.Counts[i] = n
+		s.ResumeLatency.Count += n
+	}
+	return s
+}
+
+// Var returns an expvar.Var that reports the current Stats as JSON.
+func (m *Metrics) Var() expvar.Var {
+	return expvar.Func(func() any { return m.Stats() })
+}
+
+// Publish publishes the metrics with expvar under the given name.
+// Like expvar.Publish, it panics if the name is already registered.
+func (m *Metrics) Publish(name string) {
+	expvar.Publish(name, m.Var())
+}
+
+// Mean returns the average observed duration, or zero if there were
+// no observations.
+func (h Histogram) Mean() time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	return h.Sum / time.Duration(h.Count)
+}
+
+// Quantile returns an upper bound on the q-th quantile of the
+// observed durations, where q is between 0 and 1. The result is the
+// upper bound of the bucket that holds the quantile, or the last
+// bound if the quantile falls in the overflow bucket.
+func (h Histogram) Quantile(q float64) time.Duration {
+	if h.Count == 0 || len(h.Bounds) == 0 {
+		return 0
+	}
+	rank := int64(q * float64(h.Count))
+	rank = max(0, min(rank, h.Count-1))
+	var seen int64
+	for i, n := range h.Counts {
+		seen += n
+		if seen > rank && i < len(h.Bounds) {
+			return h.Bounds[i]
+		}
+	}
+	return h.Bounds[len(h.Bounds)-1]
+}
@@ -0,0 +1,132 @@
+package coro
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestMetricsCounts(t *testing.T) {
+	var m Metrics
+
+	// Completed after two yields and a final resume.
+	resume, cancel := New(func(yield func(int) int, suspend func() int) int {
+		yield(1)
+		yield(2)
+		return 3
+	}, WithMetrics(&m))
+	for {
+		if _, running := resume(0); !running {
+			break
+		}
+	}
+	cancel()
+
+	// Canceled while suspended.
+	resume, cancel = New(func(yield func(int) int, suspend func() int) int {
+		defer func() { _ = recover() }()
+		suspend()
+		return 0
+	}, WithMetrics(&m))
+	resume(0)
+	cancel()
+
+	// Panicked.
+	resume, cancel = New(func(yield func(int) int, suspend func() int) int {
+		panic("test panic")
+	}, WithMetrics(&m))
+	func() {
+		defer func() { _ = recover() }()
+		resume(0)
+	}()
+	cancel()
+
+	// Still live.
+	resume, cancel = New(func(yield func(int) int, suspend func() int) int {
+		defer func() { _ = recover() }()
+		return yield(1)
+	}, WithMetrics(&m))
+	resume(0)
+
+	s := m.Stats()
+	if s.Created != 4 {
+		t.Errorf("Expected 4 created, got %d", s.Created)
+	}
+	if s.Completed != 1 {
+		t.Errorf("Expected 1 completed, got %d", s.Completed)
+	}
+	if s.Canceled != 1 {
+		t.Errorf("Expected 1 canceled, got %d", s.Canceled)
+	}
+	if s.Panicked != 1 {
+		t.Errorf("Expected 1 panicked, got %d", s.Panicked)
+	}
+	if s.Live != 1 {
+		t.Errorf("Expected 1 live, got %d", s.Live)
+	}
+	if s.Resumes != 6 {
+		t.Errorf("Expected 6 resumes, got %d", s.Resumes)
+	}
+	if s.ResumeLatency.Count != s.Resumes {
+		t.Errorf("Expected %d latency observations, got %d", s.Resumes, s.ResumeLatency.Count)
+	}
+
+	cancel()
+	if live := m.Stats().Live; live != 0 {
+		t.Errorf("Expected 0 live after cancel, got %d", live)
+	}
+}
+
+func TestHistogramBuckets(t *testing.T) {
+	var m Metrics
+	m.observe(0)
+	m.observe(minBucket)
+	m.observe(3 * minBucket)
+	m.observe(time.Hour)
+
+	h := m.Stats().ResumeLatency
+	if len(h.Counts) != len(h.Bounds)+1 {
+		t.Fatalf("Expected %d counts, got %d", len(h.Bounds)+1, len(h.Counts))
+	}
+	for i, want := range map[int]int64{0: 1, 1: 1, 2: 1, histogramBuckets: 1} {
+		if h.Counts[i] != want {
+			t.Errorf("Expected bucket %d to have %d observations, got %d", i, want, h.Counts[i])
+		}
+	}
+	if h.Bounds[0] != minBucket || h.Bounds[2] != 4*minBucket {
+		t.Errorf("Unexpected bounds %v", h.Bounds[:3])
+	}
+	if got := h.Quantile(0.5); got != 4*minBucket {
+		t.Errorf("Expected median bound %v, got %v", 4*minBucket, got)
+	}
+	if got := h.Quantile(1); got != h.Bounds[len(h.Bounds)-1] {
+		t.Errorf("Expected max quantile to be the last bound, got %v", got)
+	}
+	if got, want := h.Mean(), (time.Hour+4*minBucket)/4; got != want {
+		t.Errorf("Expected mean %v, got %v", want, got)
+	}
+	if (Histogram{}).Mean() != 0 || (Histogram{}).Quantile(0.5) != 0 {
+		t.Error("Expected empty histogram to report zero")
+	}
+}
+
+func TestMetricsPublish(t *testing.T) {
+	var m Metrics
+	_, cancel := New(func(yield func(int) int, suspend func() int) int {
+		return 0
+	}, WithMetrics(&m))
+	defer cancel()
+
+	name := fmt.Sprintf("coro_test_metrics_%p", &m)
+	m.Publish(name)
+
+	var s Stats
+	if err := json.Unmarshal([]byte(expvar.Get(name).String()), &s); err != nil {
+		t.Fatalf("Expected JSON stats, got error: %v", err)
+	}
+	if s.Created != 1 || s.Live != 1 {
+		t.Errorf("Expected 1 created and live coroutine, got %+v", s)
+	}
+}
@@ -0,0 +1,43 @@
+package coro
+
+import "unsafe"
+
+// Option configures a coroutine created by New. Options are applied
+// once per call to New, in the order they are given, so an option may
+// allocate state that belongs to a single coroutine.
+type Option func(*config)
+
+// config holds the settings accumulated from the options passed to
+// New. A zero config describes a plain coroutine with no extensions.
+type config struct {
+	hooks Hooks
+	name  string
+
+	// Profiler labels captured from the resuming goroutine, and the
+	// cached result of adding the coroutine's name to them. labeled
+	// reports whether the coroutine's goroutine may carry labels.
+	labels    unsafe.Pointer
+	namedFrom unsafe.Pointer
+	named     unsafe.Pointer
+	labeled   bool
+}
+
+// newConfig applies opts to a fresh config and returns it.
+func newConfig(opts []Option) *config {
+	cfg := &config{labeled: getProfLabel() != nil}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(cfg)
+		}
+	}
+	return cfg
+}
+
+// WithName returns an Option that names the coroutine. While it runs,
+// a named coroutine carries a "coro" profiler label with its name, so
+// that CPU profiles can be filtered with `go tool pprof -tagfocus`.
+func WithName(name string) Option {
+	return func(c *config) {
+		c.name = name
+	}
+}
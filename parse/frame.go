@@ -0,0 +1,19 @@
+package parse
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Frame returns a parser for frames made of a 32-bit big-endian
+// length followed by that many bytes of payload, returning the
+// payload. Frames whose length exceeds max fail with ErrTooLong
+// before any of the payload is read.
+func Frame(max int) Parser[[]byte] {
+	return Bind(Uint32(binary.BigEndian), func(n uint32) Parser[[]byte] {
+		if uint64(n) > uint64(max) {
+			return Fail[[]byte](fmt.Errorf("%w: frame of %d bytes", ErrTooLong, n))
+		}
+		return Take(int(n))
+	})
+}
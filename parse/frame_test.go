@@ -0,0 +1,22 @@
+package parse
+
+import (
+	"io"
+	"testing"
+)
+
+func TestFrame(t *testing.T) {
+	input := "\x00\x00\x00\x05hello\x00\x00\x00\x00\x00\x00\x00\x01!"
+	expectParse(t, Frame(5), input, [][]byte{[]byte("hello"), {}, []byte("!")})
+	expectError(t, Frame(4), input, ErrTooLong)
+	expectError(t, Frame(5), input[:7], io.ErrUnexpectedEOF)
+	expectError(t, Frame(5), input[:2], io.ErrUnexpectedEOF)
+}
+
+func TestFrameTooLongEarly(t *testing.T) {
+	// An oversized frame fails as soon as its length is read.
+	tk := New(Frame(16))
+	if _, err := tk.Feed([]byte("\xff\xff\xff\xff")); err == nil {
+		t.Error("Expected an error before the payload arrived")
+	}
+}
@@ -0,0 +1,73 @@
+package parse
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/textproto"
+	"strings"
+
+	"github.com/webriots/coro/stream"
+)
+
+// RequestHead is the head of an HTTP/1.1 request: its request line
+// and header fields.
+type RequestHead struct {
+	Method string
+	Target string
+	Proto  string
+	Header http.Header
+}
+
+// Request returns a parser for HTTP/1.x request heads, up to and
+// including the empty line that ends the header fields. The body, if
+// any, is left unread. Heads longer than max bytes fail with
+// ErrTooLong, and malformed heads with an error wrapping ErrSyntax.
+// As recommended by RFC 9112, empty lines before the request line are
+// ignored.
+func Request(max int) Parser[*RequestHead] {
+	return func(in *stream.Input) (*RequestHead, error) {
+		remaining := max
+		line := func() (string, error) {
+			p, err := Until('\n', remaining)(in)
+			if err != nil {
+				return "", err
+			}
+			remaining -= len(p) + 1
+			return strings.TrimSuffix(string(p), "\r"), nil
+		}
+
+		var req string
+		for req == "" {
+			var err error
+			if req, err = line(); err != nil {
+				return nil, err
+			}
+		}
+		method, rest, ok1 := strings.Cut(req, " ")
+		target, proto, ok2 := strings.Cut(rest, " ")
+		if !ok1 || !ok2 || method == "" || target == "" || !strings.HasPrefix(proto, "HTTP/1.") {
+			return nil, fmt.Errorf("%w: malformed request line %q", ErrSyntax, req)
+		}
+
+		head := &RequestHead{Method: method, Target: target, Proto: proto, Header: http.Header{}}
+		for {
+			field, err := line()
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			if err != nil {
+				return nil, err
+			}
+			if field == "" {
+				return head, nil
+			}
+			name, value, ok := strings.Cut(field, ":")
+			if !ok || name == "" || strings.ContainsAny(name, " \t") {
+				return nil, fmt.Errorf("%w: malformed header field %q", ErrSyntax, field)
+			}
+			key := textproto.CanonicalMIMEHeaderKey(name)
+			head.Header[key] = append(head.Header[key], strings.TrimSpace(value))
+		}
+	}
+}
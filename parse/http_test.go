@@ -0,0 +1,49 @@
+package parse
+
+import (
+	"io"
+	"net/http"
+	"testing"
+)
+
+func TestRequest(t *testing.T) {
+	input := "GET /index.html HTTP/1.1\r\n" +
+		"Host: example.com\r\n" +
+		"accept:  text/html \r\n" +
+		"Accept: */*\r\n" +
+		"\r\n" +
+		"\r\n" +
+		"POST /submit HTTP/1.0\n" +
+		"\n"
+
+	expectParse(t, Request(1024), input, []*RequestHead{
+		{
+			Method: "GET",
+			Target: "/index.html",
+			Proto:  "HTTP/1.1",
+			Header: http.Header{
+				"Host":   {"example.com"},
+				"Accept": {"text/html", "*/*"},
+			},
+		},
+		{Method: "POST", Target: "/submit", Proto: "HTTP/1.0", Header: http.Header{}},
+	})
+}
+
+func TestRequestErrors(t *testing.T) {
+	for _, test := range []struct {
+		input  string
+		target error
+	}{
+		{"GET /\r\n\r\n", ErrSyntax},
+		{"GET / HTTP/2\r\n\r\n", ErrSyntax},
+		{"GET  HTTP/1.1\r\n\r\n", ErrSyntax},
+		{"GET / HTTP/1.1\r\nHost example.com\r\n\r\n", ErrSyntax},
+		{"GET / HTTP/1.1\r\nHost : example.com\r\n\r\n", ErrSyntax},
+		{"GET / HTTP/1.1\r\nHost: example.com\r\n", io.ErrUnexpectedEOF},
+		{"GET / HTTP/1.1\r\nHost: example.com", io.ErrUnexpectedEOF},
+		{"GET / HTTP/1.1\r\nHost: a-very-long-host-name.example.com\r\n\r\n", ErrTooLong},
+	} {
+		expectError(t, Request(48), test.input, test.target)
+	}
+}
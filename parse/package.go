@@ -0,0 +1,13 @@
+// Package parse is a toolkit for incremental push parsers of binary
+// and text protocols. A Parser is a plain function that reads one
+// message from a stream.Input; parsers are built from primitives such
+// as Take, Until and Uint32 and composed with Map and Bind.
+//
+// New runs a parser repeatedly over input fed to it in arbitrary
+// fragments. Whenever a parser needs bytes that have not arrived
+// yet, its coroutine suspends until the next Feed, so parsing resumes
+// exactly where a partial read left off.
+//
+// Line, Frame and Request are ready-made parsers for line-delimited
+// text, length-prefixed frames and HTTP/1.1 request heads.
+package parse
@@ -0,0 +1,204 @@
+package parse
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/webriots/coro"
+	"github.com/webriots/coro/stream"
+)
+
+var (
+	// ErrSyntax is wrapped by the errors of parsers that find input
+	// that does not match what they expect.
+	ErrSyntax = errors.New("parse: syntax error")
+
+	// ErrTooLong is returned by parsers whose input exceeds their size
+	// limit.
+	ErrTooLong = errors.New("parse: message too long")
+)
+
+// Parser reads a value from in, suspending whenever it needs more
+// input than has been fed. It returns io.EOF only if the input ended
+// before the value began, and io.ErrUnexpectedEOF if it ended part
+// way through.
+type Parser[T any] func(in *stream.Input) (T, error)
+
+// New returns a tokenizer that parses a sequence of messages with p
+// from the input fed to it. The first error ends parsing, and input
+// that ends part way through a message fails with
+// io.ErrUnexpectedEOF. Options are passed through to coro.New.
+func New[T any](p Parser[T], opts ...coro.Option) *stream.Tokenizer[T] {
+	return stream.NewTokenizer(Messages(p), opts...)
+}
+
+// Messages returns a tokenize function that parses a sequence of
+// messages with p until the input ends.
+func Messages[T any](p Parser[T]) stream.TokenizeFunc[T] {
+	return func(in *stream.Input, emit func(T)) error {
+		for {
+			if _, err := in.Peek(1); err == io.EOF {
+				return nil
+			}
+			msg, err := p(in)
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			if err != nil {
+				return err
+			}
+			emit(msg)
+		}
+	}
+}
+
+// Map returns a parser that parses a value with p and converts it
+// with f.
+func Map[T, U any](p Parser[T], f func(T) (U, error)) Parser[U] {
+	return func(in *stream.Input) (U, error) {
+		v, err := p(in)
+		if err != nil {
+			var zero U
+			return zero, err
+		}
+		return f(v)
+	}
+}
+
+// Bind returns a parser that parses a value with p and then parses
+// the rest of its input with the parser that f returns for it, as
+// when a header determines the shape of a body.
+func Bind[T, U any](p Parser[T], f func(T) Parser[U]) Parser[U] {
+	return func(in *stream.Input) (U, error) {
+		v, err := p(in)
+		if err != nil {
+			var zero U
+			return zero, err
+		}
+		u, err := f(v)(in)
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return u, err
+	}
+}
+
+// Fail returns a parser that consumes nothing and fails with err.
+func Fail[T any](err error) Parser[T] {
+	return func(*stream.Input) (T, error) {
+		var zero T
+		return zero, err
+	}
+}
+
+// Repeat returns a parser that parses n values with p. It panics if n
+// is negative, so counts read from the input, as with Bind, must be
+// checked first.
+func Repeat[T any](p Parser[T], n int) Parser[[]T] {
+	if n < 0 {
+		panic("parse: negative Repeat count")
+	}
+	return func(in *stream.Input) ([]T, error) {
+		values := make([]T, 0, n)
+		for i := range n {
+			v, err := p(in)
+			if err == io.EOF && i > 0 {
+				err = io.ErrUnexpectedEOF
+			}
+			if err != nil {
+				return values, err
+			}
+			values = append(values, v)
+		}
+		return values, nil
+	}
+}
+
+// Byte returns a parser that reads a single byte.
+func Byte() Parser[byte] {
+	return func(in *stream.Input) (byte, error) {
+		return in.ReadByte()
+	}
+}
+
+// Take returns a parser that reads exactly n bytes.
+func Take(n int) Parser[[]byte] {
+	return func(in *stream.Input) ([]byte, error) {
+		return in.Next(n)
+	}
+}
+
+// Literal returns a parser that reads s, failing with ErrSyntax if
+// the input holds anything else.
+func Literal(s string) Parser[string] {
+	return func(in *stream.Input) (string, error) {
+		p, err := in.Next(len(s))
+		if err != nil {
+			return "", err
+		}
+		if string(p) != s {
+			return "", fmt.Errorf("%w: expected %q, got %q", ErrSyntax, s, p)
+		}
+		return s, nil
+	}
+}
+
+// Until returns a parser that reads the bytes up to the first
+// occurrence of delim, consuming but not returning the delimiter. It
+// fails with ErrTooLong if more than max bytes precede the delimiter.
+// If the input ends first, it returns the remaining bytes and
+// io.ErrUnexpectedEOF.
+func Until(delim byte, max int) Parser[[]byte] {
+	return func(in *stream.Input) ([]byte, error) {
+		for scanned := 0; ; {
+			// Peeking at the buffered bytes never suspends.
+			buf, _ := in.Peek(in.Buffered())
+			if i := bytes.IndexByte(buf[scanned:], delim); i >= 0 {
+				n := scanned + i
+				if n > max {
+					return nil, ErrTooLong
+				}
+				p, _ := in.Next(n + 1)
+				return p[:n], nil
+			}
+			scanned = len(buf)
+			if scanned > max {
+				return nil, ErrTooLong
+			}
+			if _, err := in.Peek(scanned + 1); err != nil {
+				if scanned == 0 {
+					return nil, io.EOF
+				}
+				p, _ := in.Next(scanned)
+				return p, io.ErrUnexpectedEOF
+			}
+		}
+	}
+}
+
+// Uint16 returns a parser that reads a 16-bit integer in the given
+// byte order.
+func Uint16(order binary.ByteOrder) Parser[uint16] {
+	return Map(Take(2), func(p []byte) (uint16, error) {
+		return order.Uint16(p), nil
+	})
+}
+
+// Uint32 returns a parser that reads a 32-bit integer in the given
+// byte order.
+func Uint32(order binary.ByteOrder) Parser[uint32] {
+	return Map(Take(4), func(p []byte) (uint32, error) {
+		return order.Uint32(p), nil
+	})
+}
+
+// Uint64 returns a parser that reads a 64-bit integer in the given
+// byte order.
+func Uint64(order binary.ByteOrder) Parser[uint64] {
+	return Map(Take(8), func(p []byte) (uint64, error) {
+		return order.Uint64(p), nil
+	})
+}
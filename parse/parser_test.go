@@ -0,0 +1,124 @@
+package parse
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/webriots/coro/stream"
+)
+
+// parseAll feeds input to a parser for p in fragments of size bytes
+// and closes it, returning all of the messages and the first error.
+func parseAll[T any](p Parser[T], input string, size int) ([]T, error) {
+	tk := New(p)
+	defer tk.Stop()
+	var msgs []T
+	for i := 0; i < len(input); i += size {
+		out, err := tk.Feed([]byte(input[i:min(i+size, len(input))]))
+		msgs = append(msgs, out...)
+		if err != nil {
+			return msgs, err
+		}
+	}
+	out, err := tk.Close()
+	return append(msgs, out...), err
+}
+
+// expectParse checks that p parses input into expected, however the
+// input is split into fragments.
+func expectParse[T any](t *testing.T, p Parser[T], input string, expected []T) {
+	t.Helper()
+	for size := 1; size <= max(len(input), 1); size++ {
+		msgs, err := parseAll(p, input, size)
+		if err != nil {
+			t.Fatalf("Fragment size %d: expected no error, got %v", size, err)
+		}
+		if !reflect.DeepEqual(msgs, expected) {
+			t.Fatalf("Fragment size %d: expected %v, got %v", size, expected, msgs)
+		}
+	}
+}
+
+// expectError checks that p fails with target on input, however the
+// input is split into fragments.
+func expectError[T any](t *testing.T, p Parser[T], input string, target error) {
+	t.Helper()
+	for size := 1; size <= max(len(input), 1); size++ {
+		if _, err := parseAll(p, input, size); !errors.Is(err, target) {
+			t.Fatalf("Fragment size %d: expected %v, got %v", size, target, err)
+		}
+	}
+}
+
+func TestUntil(t *testing.T) {
+	expectParse(t, Until(';', 5), "ab;;hello;", [][]byte{[]byte("ab"), {}, []byte("hello")})
+	expectError(t, Until(';', 5), "ab;toolong;", ErrTooLong)
+	expectError(t, Until(';', 5), "toolong", ErrTooLong)
+	expectError(t, Until(';', 5), "ab;cd", io.ErrUnexpectedEOF)
+}
+
+func TestLiteral(t *testing.T) {
+	expectParse(t, Literal("ping"), "pingping", []string{"ping", "ping"})
+	expectError(t, Literal("ping"), "pingpong", ErrSyntax)
+	expectError(t, Literal("ping"), "pi", io.ErrUnexpectedEOF)
+}
+
+func TestIntegers(t *testing.T) {
+	input := "\x01\x02\x03\x04\x05\x06\x07\x08"
+	expectParse(t, Uint16(binary.BigEndian), input, []uint16{0x0102, 0x0304, 0x0506, 0x0708})
+	expectParse(t, Uint32(binary.LittleEndian), input, []uint32{0x04030201, 0x08070605})
+	expectParse(t, Uint64(binary.BigEndian), input, []uint64{0x0102030405060708})
+	expectError(t, Uint32(binary.BigEndian), input[:6], io.ErrUnexpectedEOF)
+}
+
+func TestBind(t *testing.T) {
+	// A count followed by that many bytes.
+	p := Bind(Byte(), func(n byte) Parser[[]byte] {
+		return Repeat(Byte(), int(n))
+	})
+	expectParse(t, p, "\x02ab\x00\x01c", [][]byte{[]byte("ab"), {}, []byte("c")})
+	expectError(t, p, "\x03ab", io.ErrUnexpectedEOF)
+}
+
+func TestRepeatNegative(t *testing.T) {
+	defer func() {
+		if p := recover(); p != "parse: negative Repeat count" {
+			t.Errorf("Expected a negative count panic, got %v", p)
+		}
+	}()
+	Repeat(Byte(), -1)
+}
+
+func TestMap(t *testing.T) {
+	failed := errors.New("odd")
+	p := Map(Byte(), func(b byte) (int, error) {
+		if b%2 == 1 {
+			return 0, failed
+		}
+		return int(b) / 2, nil
+	})
+	expectParse(t, p, "\x02\x04", []int{1, 2})
+	expectError(t, p, "\x02\x03", failed)
+}
+
+func TestFail(t *testing.T) {
+	failed := errors.New("failed")
+	expectError(t, Fail[int](failed), "x", failed)
+	expectParse(t, Fail[int](failed), "", nil)
+}
+
+func TestMessagesStop(t *testing.T) {
+	var stopped bool
+	tk := New(func(in *stream.Input) (byte, error) {
+		defer func() { stopped = true }()
+		return in.ReadByte()
+	})
+	tk.Feed([]byte("a"))
+	tk.Stop()
+	if !stopped {
+		t.Error("Expected the parser to be stopped")
+	}
+}
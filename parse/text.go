@@ -0,0 +1,29 @@
+package parse
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/webriots/coro/stream"
+)
+
+// Line returns a parser that reads a line terminated by "\n" or
+// "\r\n", without its terminator. Lines longer than max bytes fail
+// with ErrTooLong. A final line is accepted without a terminator.
+func Line(max int) Parser[string] {
+	until := Until('\n', max+1)
+	return func(in *stream.Input) (string, error) {
+		p, err := until(in)
+		if err == io.ErrUnexpectedEOF {
+			err = nil
+		}
+		if err != nil {
+			return "", err
+		}
+		p = bytes.TrimSuffix(p, []byte("\r"))
+		if len(p) > max {
+			return "", ErrTooLong
+		}
+		return string(p), nil
+	}
+}
@@ -0,0 +1,10 @@
+package parse
+
+import "testing"
+
+func TestLine(t *testing.T) {
+	expectParse(t, Line(8), "one\r\ntwo\n\nthree", []string{"one", "two", "", "three"})
+	expectParse(t, Line(3), "abc\r\n", []string{"abc"})
+	expectError(t, Line(3), "abcd\r\n", ErrTooLong)
+	expectError(t, Line(3), "abc\rd\n", ErrTooLong)
+}
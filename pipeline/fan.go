@@ -0,0 +1,73 @@
+package pipeline
+
+import (
+	"slices"
+
+	"github.com/webriots/coro/gen"
+)
+
+// FanOut returns a stage that runs n copies of s over a shared input.
+// Each copy pulls the next input whenever it needs one, and their
+// outputs are merged in round-robin order as by FanIn. This is useful
+// for stages that buffer or batch per copy, such as per-shard
+// aggregation. FanOut panics if n is not positive.
+func FanOut[In, Out any](n int, s Stage[In, Out]) Stage[In, Out] {
+	if n <= 0 {
+		panic("pipeline: fan-out must be positive")
+	}
+	return func(src *gen.Generator[In], yield func(Out)) error {
+		copies := make([]*gen.Generator[Out], n)
+		for i := range copies {
+			copies[i] = s.apply(share(src))
+		}
+		merged := FanIn(copies...)
+		defer merged.Stop()
+		for {
+			v, ok := merged.Next()
+			if !ok {
+				return nil
+			}
+			yield(v)
+		}
+	}
+}
+
+// FanIn returns a generator that merges the values of srcs, taking
+// one value from each in turn and dropping each source once it is
+// exhausted. Stopping the generator stops every source.
+func FanIn[T any](srcs ...*gen.Generator[T]) *gen.Generator[T] {
+	return gen.New(func(yield func(T)) {
+		defer func() {
+			for _, src := range srcs {
+				src.Stop()
+			}
+		}()
+		active := slices.Clone(srcs)
+		for len(active) > 0 {
+			for i := 0; i < len(active); {
+				v, ok := active[i].Next()
+				if !ok {
+					active = slices.Delete(active, i, i+1)
+					continue
+				}
+				yield(v)
+				i++
+			}
+		}
+	})
+}
+
+// share returns a generator that reads from src without taking
+// ownership of it, so that stopping it leaves src running for the
+// other readers.
+func share[T any](src *gen.Generator[T]) *gen.Generator[T] {
+	return gen.New(func(yield func(T)) {
+		for {
+			v, ok := src.Next()
+			if !ok {
+				return
+			}
+			yield(v)
+		}
+	})
+}
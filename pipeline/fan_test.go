@@ -0,0 +1,84 @@
+package pipeline
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/webriots/coro/gen"
+)
+
+func TestFanIn(t *testing.T) {
+	values := gen.Collect(FanIn(gen.Of(1, 2, 3), gen.Of[int](), gen.Of(10), gen.Of(20, 30)))
+	if expected := []int{1, 10, 20, 2, 30, 3}; !reflect.DeepEqual(values, expected) {
+		t.Errorf("Expected %v, got %v", expected, values)
+	}
+
+	var pulled int
+	var stopped bool
+	g := FanIn(gen.Of(1), source(&pulled, &stopped, 1, 2))
+	g.Next()
+	g.Next()
+	g.Stop()
+	if !stopped {
+		t.Error("Expected every source to be stopped")
+	}
+}
+
+func TestFanOut(t *testing.T) {
+	// Each copy sums pairs of values, so the copies interleave their
+	// pulls from the shared input.
+	pairs := FanOut(2, func(src *gen.Generator[int], yield func(int)) error {
+		for {
+			a, ok := src.Next()
+			if !ok {
+				return nil
+			}
+			b, _ := src.Next()
+			yield(a + b)
+		}
+	})
+
+	var out []int
+	err := New(pairs).Run(gen.Of(1, 2, 3, 4, 5, 6, 7), func(n int) error {
+		out = append(out, n)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if expected := []int{3, 7, 11, 7}; !reflect.DeepEqual(out, expected) {
+		t.Errorf("Expected %v, got %v", expected, out)
+	}
+}
+
+func TestFanOutError(t *testing.T) {
+	var pulled int
+	var stopped bool
+	fail := errors.New("copy failed")
+	stage := FanOut(3, func(src *gen.Generator[int], yield func(int)) error {
+		v, ok := src.Next()
+		if ok && v == 2 {
+			return fail
+		}
+		yield(v)
+		return nil
+	})
+
+	err := New(stage).Run(source(&pulled, &stopped, 1, 2, 3, 4), func(int) error { return nil })
+	if !errors.Is(err, fail) {
+		t.Errorf("Expected the copy's error, got %v", err)
+	}
+	if !stopped || pulled != 2 {
+		t.Errorf("Expected the source to be stopped after 2 values, got %d pulled, stopped %t", pulled, stopped)
+	}
+}
+
+func TestFanOutInvalid(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected panic but got none")
+		}
+	}()
+	FanOut(0, Filter(func(int) bool { return true }))
+}
@@ -0,0 +1,9 @@
+// Package pipeline builds stream processing pipelines out of
+// coroutines. Each stage is a coroutine that pulls values from its
+// upstream and pushes results downstream, so a slow consumer
+// naturally holds back every stage before it without any buffering,
+// channels or goroutines, and a pipeline runs deterministically.
+//
+// A stage that fails returns an error, which unwinds every stage of
+// the pipeline, stopping their coroutines, and is returned by Run.
+package pipeline
@@ -0,0 +1,130 @@
+package pipeline
+
+import (
+	"errors"
+
+	"github.com/webriots/coro/gen"
+)
+
+// ErrStop may be returned by a sink to end Run early without
+// reporting an error.
+var ErrStop = errors.New("pipeline: stop")
+
+// Stage transforms a stream of In values into a stream of Out
+// values. It pulls its input from src with Next, and pushes each
+// output with yield, which blocks until the next stage asks for
+// another value. Returning nil ends the stage's output, and returning
+// an error fails the whole pipeline.
+type Stage[In, Out any] func(src *gen.Generator[In], yield func(Out)) error
+
+// Pipeline is a sequence of stages that turns a stream of In values
+// into a stream of Out values. Pipelines are immutable, and may be
+// applied to any number of sources.
+type Pipeline[In, Out any] struct {
+	apply func(*gen.Generator[In]) *gen.Generator[Out]
+}
+
+// New returns a pipeline consisting of the single stage s.
+func New[In, Out any](s Stage[In, Out]) Pipeline[In, Out] {
+	return Pipeline[In, Out]{apply: s.apply}
+}
+
+// Then returns a pipeline that feeds the output of p through s.
+func Then[In, Mid, Out any](p Pipeline[In, Mid], s Stage[Mid, Out]) Pipeline[In, Out] {
+	return Pipeline[In, Out]{apply: func(src *gen.Generator[In]) *gen.Generator[Out] {
+		return s.apply(p.apply(src))
+	}}
+}
+
+// Apply connects the pipeline to src and returns a generator of its
+// output. Nothing runs until the generator's values are requested.
+// Stopping the generator stops every stage and src. If a stage fails,
+// Next panics with the stage's error wrapped with its stack trace.
+func (p Pipeline[In, Out]) Apply(src *gen.Generator[In]) *gen.Generator[Out] {
+	return p.apply(src)
+}
+
+// Run connects the pipeline to src and passes each output to sink
+// until the pipeline is exhausted, the sink returns an error, or a
+// stage fails. Every stage and src are stopped before Run returns.
+// A stage's error, or the value of a panic in a stage, is returned
+// wrapped with its stack trace, so it should be examined with
+// errors.Is or errors.As. If the sink returns ErrStop, Run returns
+// nil.
+func (p Pipeline[In, Out]) Run(src *gen.Generator[In], sink func(Out) error) error {
+	out := p.apply(src)
+	defer out.Stop()
+	for {
+		v, ok, err := next(out)
+		if err != nil || !ok {
+			return err
+		}
+		if err := sink(v); err != nil {
+			if errors.Is(err, ErrStop) {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// next calls g.Next, converting a failed stage into an error.
+func next[T any](g *gen.Generator[T]) (v T, ok bool, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			perr, isErr := p.(error)
+			if !isErr {
+				panic(p)
+			}
+			err = perr
+		}
+	}()
+	v, ok = g.Next()
+	return
+}
+
+// apply runs s as a coroutine reading from src. It panics with the
+// stage's error, which is how failures short-circuit through the
+// stages downstream.
+func (s Stage[In, Out]) apply(src *gen.Generator[In]) *gen.Generator[Out] {
+	return gen.New(func(yield func(Out)) {
+		defer src.Stop()
+		if err := s(src, yield); err != nil {
+			panic(err)
+		}
+	})
+}
+
+// Map returns a stage that applies f to each value. If f returns an
+// error, the pipeline fails.
+func Map[In, Out any](f func(In) (Out, error)) Stage[In, Out] {
+	return func(src *gen.Generator[In], yield func(Out)) error {
+		for {
+			v, ok := src.Next()
+			if !ok {
+				return nil
+			}
+			out, err := f(v)
+			if err != nil {
+				return err
+			}
+			yield(out)
+		}
+	}
+}
+
+// Filter returns a stage that passes on the values for which keep
+// returns true.
+func Filter[T any](keep func(T) bool) Stage[T, T] {
+	return func(src *gen.Generator[T], yield func(T)) error {
+		for {
+			v, ok := src.Next()
+			if !ok {
+				return nil
+			}
+			if keep(v) {
+				yield(v)
+			}
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/webriots/coro/gen"
+)
+
+// source returns a generator of values that records how many were
+// pulled and whether it was stopped.
+func source[T any](pulled *int, stopped *bool, values ...T) *gen.Generator[T] {
+	return gen.New(func(yield func(T)) {
+		defer func() { *stopped = true }()
+		for _, v := range values {
+			*pulled++
+			yield(v)
+		}
+	})
+}
+
+func TestPipelineRun(t *testing.T) {
+	p := Then(
+		Then(
+			New(Map(strconv.Atoi)),
+			Filter(func(n int) bool { return n%2 == 1 }),
+		),
+		Map(func(n int) (string, error) { return strings.Repeat("*", n), nil }),
+	)
+
+	var out []string
+	err := p.Run(gen.Of("1", "2", "3"), func(s string) error {
+		out = append(out, s)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if expected := []string{"*", "***"}; !reflect.DeepEqual(out, expected) {
+		t.Errorf("Expected %v, got %v", expected, out)
+	}
+}
+
+func TestPipelineBackpressure(t *testing.T) {
+	var pulled int
+	var stopped bool
+	p := New(Map(func(n int) (int, error) { return n * 10, nil }))
+	out := p.Apply(source(&pulled, &stopped, 1, 2, 3, 4))
+
+	if pulled != 0 {
+		t.Errorf("Expected nothing to be pulled before Next, got %d", pulled)
+	}
+	if v, _ := out.Next(); v != 10 || pulled != 1 {
+		t.Errorf("Expected one value pulled for one output, got %d pulled", pulled)
+	}
+	out.Next()
+	if pulled != 2 {
+		t.Errorf("Expected 2 values pulled, got %d", pulled)
+	}
+
+	out.Stop()
+	if !stopped {
+		t.Error("Expected the source to be stopped")
+	}
+}
+
+func TestPipelineError(t *testing.T) {
+	var pulled int
+	var stopped bool
+	var reached []int
+	p := Then(
+		New(Map(strconv.Atoi)),
+		Map(func(n int) (int, error) {
+			reached = append(reached, n)
+			return n, nil
+		}),
+	)
+
+	err := p.Run(source(&pulled, &stopped, "1", "x", "3"), func(int) error { return nil })
+	var numErr *strconv.NumError
+	if !errors.As(err, &numErr) {
+		t.Fatalf("Expected a *strconv.NumError, got %v", err)
+	}
+	if !strings.Contains(err.Error(), `parsing "x"`) {
+		t.Errorf("Expected error about 'x', got '%v'", err)
+	}
+	if !reflect.DeepEqual(reached, []int{1}) {
+		t.Errorf("Expected later stages to see only [1], got %v", reached)
+	}
+	if pulled != 2 || !stopped {
+		t.Errorf("Expected the source to be stopped after 2 values, got %d pulled, stopped %t", pulled, stopped)
+	}
+}
+
+func TestPipelinePanic(t *testing.T) {
+	p := New(Map(func(n int) (int, error) { panic("test panic") }))
+	err := p.Run(gen.Of(1), func(int) error { return nil })
+	if err == nil || err.Error() != "test panic" {
+		t.Errorf("Expected error 'test panic', got '%v'", err)
+	}
+}
+
+func TestPipelineSink(t *testing.T) {
+	var pulled int
+	var stopped bool
+	p := New(Filter(func(int) bool { return true }))
+
+	var out []int
+	err := p.Run(source(&pulled, &stopped, 1, 2, 3), func(n int) error {
+		out = append(out, n)
+		if n == 2 {
+			return ErrStop
+		}
+		return nil
+	})
+	if err != nil {
+		t.Errorf("Expected ErrStop to end Run without error, got %v", err)
+	}
+	if !reflect.DeepEqual(out, []int{1, 2}) || !stopped {
+		t.Errorf("Expected [1 2] and a stopped source, got %v, %t", out, stopped)
+	}
+
+	err = p.Run(gen.Of(1, 2), func(int) error { return fmt.Errorf("done: %w", ErrStop) })
+	if err != nil {
+		t.Errorf("Expected a wrapped ErrStop to end Run without error, got %v", err)
+	}
+
+	sinkErr := errors.New("sink error")
+	err = p.Run(gen.Of(1), func(int) error { return sinkErr })
+	if err != sinkErr {
+		t.Errorf("Expected the sink's error, got %v", err)
+	}
+}
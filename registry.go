@@ -0,0 +1,245 @@
+package coro
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Status describes what a live coroutine is doing.
+type Status int32
+
+const (
+	// StatusCreated means the coroutine has not been resumed yet.
+	StatusCreated Status = iota
+	// StatusRunning means the coroutine is executing inside a call
+	// to resume.
+	StatusRunning
+	// StatusSuspended means the coroutine has yielded or suspended
+	// and is waiting to be resumed.
+	StatusSuspended
+)
+
+// String returns the lowercase name of the status.
+func (s Status) String() string {
+	switch s {
+	case StatusCreated:
+		return "created"
+	case StatusRunning:
+		return "running"
+	case StatusSuspended:
+		return "suspended"
+	}
+	return fmt.Sprintf("Status(%d)", int32(s))
+}
+
+// Info describes a live coroutine in the registry.
+type Info struct {
+	// ID uniquely identifies the coroutine within the process.
+	ID uint64
+	// Name is the name given with WithName, if any.
+	Name string
+	// Status is what the coroutine is currently doing.
+	Status Status
+	// Created is the time the coroutine was created.
+	Created time.Time
+	// Resumes is the number of times the coroutine was resumed.
+	Resumes int64
+	// LastYield is the time the coroutine last yielded or
+	// suspended, or the zero time if it never has.
+	LastYield time.Time
+	// Stack is the stack trace of the call to New that created the
+	// coroutine.
+	Stack string
+}
+
+// registry is the global set of live coroutines. enabled is set under
+// the lock, but read without it by New.
+var registry struct {
+	sync.Mutex
+	enabled atomic.Bool
+	live    map[*record]struct{}
+}
+
+// lastID is the ID of the most recently tracked coroutine.
+var lastID atomic.Uint64
+
+// record tracks a single coroutine for the registry and for leak
+// detection. Its counters are updated by the coroutine and read by
+// Dump from other goroutines.
+type record struct {
+	id        uint64
+	name      string
+	created   time.Time
+	pcs       []uintptr
+	status    atomic.Int32
+	resumes   atomic.Int64
+	lastYield atomic.Int64
+}
+
+// EnableRegistry starts recording every coroutine created by New in a
+// global registry until it terminates, along with its name, status,
+// creation stack, resume count and last yield time. The registry is
+// the coroutine equivalent of a goroutine dump, and can be inspected
+// with Coroutines, Dump and Handler.
+//
+// The registry adds overhead to New and resume and is intended for
+// debugging. The returned function restores the previous setting.
+func EnableRegistry() (disable func()) {
+	registry.Lock()
+	defer registry.Unlock()
+	enabled := registry.enabled.Load()
+	registry.enabled.Store(true)
+	if registry.live == nil {
+		registry.live = make(map[*record]struct{})
+	}
+	return func() {
+		registry.Lock()
+		defer registry.Unlock()
+		registry.enabled.Store(enabled)
+	}
+}
+
+// Coroutines returns a snapshot of the live coroutines in the
+// registry, ordered by ID.
+func Coroutines() []Info {
+	registry.Lock()
+	recs := make([]*record, 0, len(registry.live))
+	for rec := range registry.live {
+		recs = append(recs, rec)
+	}
+	registry.Unlock()
+
+	slices.SortFunc(recs, func(a, b *record) int { return cmp.Compare(a.id, b.id) })
+	infos := make([]Info, len(recs))
+	for i, rec := range recs {
+		infos[i] = rec.info()
+	}
+	return infos
+}
+
+// Dump writes a description of every live coroutine in the registry
+// to w, in a format similar to a goroutine dump.
+func Dump(w io.Writer) error {
+	now := time.Now()
+	for _, info := range Coroutines() {
+		var sb strings.Builder
+		fmt.Fprintf(&sb, "coroutine %d", info.ID)
+		if info.Name != "" {
+			fmt.Fprintf(&sb, " %q", info.Name)
+		}
+		fmt.Fprintf(&sb, " [%s, %d resumes", info.Status, info.Resumes)
+		if !info.LastYield.IsZero() {
+			fmt.Fprintf(&sb, ", last yield %s ago", now.Sub(info.LastYield).Round(time.Millisecond))
+		}
+		fmt.Fprintf(&sb, ", created %s ago]:\n%s\n", now.Sub(info.Created).Round(time.Millisecond), info.Stack)
+		if _, err := io.WriteString(w, sb.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Handler returns an http.Handler that serves Dump as plain text,
+// for use as a debug endpoint.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_ = Dump(w)
+	})
+}
+
+// track creates a record for a new coroutine if the registry or leak
+// detection is enabled. It returns the owner that must be kept alive
+// by the coroutine's resume and cancel functions, or nil. When neither
+// is enabled, it takes no locks.
+func (c *config) track() *owner {
+	if !registry.enabled.Load() && !leaks.enabled.Load() {
+		return nil
+	}
+
+	pcs := make([]uintptr, 32)
+	rec := &record{
+		id:      lastID.Add(1),
+		name:    c.name,
+		created: time.Now(),
+		pcs:     pcs[:runtime.Callers(3, pcs)],
+	}
+	registered := rec.register()
+	untrack := func() {
+		untrackLeak(rec)
+		if registered {
+			rec.unregister()
+		}
+	}
+	c.hooks = c.hooks.then(Hooks{
+		BeforeResume: func() {
+			rec.resumes.Add(1)
+			rec.status.Store(int32(StatusRunning))
+		},
+		AfterResume: func() {
+			rec.status.Store(int32(StatusSuspended))
+		},
+		OnYield: func() {
+			rec.lastYield.Store(time.Now().UnixNano())
+		},
+		OnComplete: untrack,
+		OnPanic:    func(error) { untrack() },
+		OnCancel:   untrack,
+	})
+	return trackLeak(rec)
+}
+
+// register adds rec to the registry if it is enabled.
+func (r *record) register() bool {
+	registry.Lock()
+	defer registry.Unlock()
+	if registry.enabled.Load() {
+		registry.live[r] = struct{}{}
+	}
+	return registry.enabled.Load()
+}
+
+// unregister removes rec from the registry.
+func (r *record) unregister() {
+	registry.Lock()
+	defer registry.Unlock()
+	delete(registry.live, r)
+}
+
+// info returns a snapshot of the record.
+func (r *record) info() Info {
+	info := Info{
+		ID:      r.id,
+		Name:    r.name,
+		Status:  Status(r.status.Load()),
+		Created: r.created,
+		Resumes: r.resumes.Load(),
+		Stack:   r.stack(),
+	}
+	if ns := r.lastYield.Load(); ns != 0 {
+		info.LastYield = time.Unix(0, ns)
+	}
+	return info
+}
+
+// stack formats the creation stack of the coroutine.
+func (r *record) stack() string {
+	var sb strings.Builder
+	frames := runtime.CallersFrames(r.pcs)
+	for {
+		f, more := frames.Next()
+		fmt.Fprintf(&sb, "%s\n\t%s:%d\n", f.Function, f.File, f.Line)
+		if !more {
+			break
+		}
+	}
+	return sb.String()
+}
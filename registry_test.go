@@ -0,0 +1,125 @@
+package coro
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRegistryCoroutines(t *testing.T) {
+	disable := EnableRegistry()
+	defer disable()
+
+	resume, cancel := New(func(yield func(int) int, suspend func() int) int {
+		yield(1)
+		yield(2)
+		return 3
+	}, WithName("tokenizer"))
+	defer cancel()
+
+	_, cancelIdle := New(func(yield func(int) int, suspend func() int) int {
+		return 0
+	})
+
+	before := time.Now()
+	resume(0)
+	resume(0)
+
+	infos := Coroutines()
+	if len(infos) != 2 {
+		t.Fatalf("Expected 2 live coroutines, got %d", len(infos))
+	}
+	info := infos[0]
+	if info.Name != "tokenizer" {
+		t.Errorf("Expected name 'tokenizer', got '%s'", info.Name)
+	}
+	if info.Status != StatusSuspended {
+		t.Errorf("Expected status suspended, got %s", info.Status)
+	}
+	if info.Resumes != 2 {
+		t.Errorf("Expected 2 resumes, got %d", info.Resumes)
+	}
+	if info.LastYield.Before(before) {
+		t.Errorf("Expected last yield after %v, got %v", before, info.LastYield)
+	}
+	if !strings.Contains(info.Stack, "TestRegistryCoroutines") {
+		t.Errorf("Expected creation stack to contain the test, got '%s'", info.Stack)
+	}
+	if idle := infos[1]; idle.Status != StatusCreated || idle.ID <= info.ID || !idle.LastYield.IsZero() {
+		t.Errorf("Expected a newer idle coroutine, got %+v", idle)
+	}
+
+	cancelIdle()
+	resume(0)
+	if infos := Coroutines(); len(infos) != 0 {
+		t.Errorf("Expected terminated coroutines to be removed, got %d", len(infos))
+	}
+}
+
+func TestRegistryStatusRunning(t *testing.T) {
+	disable := EnableRegistry()
+	defer disable()
+
+	var status Status
+	resume, cancel := New(func(yield func(int) int, suspend func() int) int {
+		status = Coroutines()[0].Status
+		return 0
+	})
+	defer cancel()
+
+	resume(0)
+	if status != StatusRunning {
+		t.Errorf("Expected status running, got %s", status)
+	}
+}
+
+func TestRegistryDisabled(t *testing.T) {
+	EnableRegistry()()
+
+	_, cancel := New(func(yield func(int) int, suspend func() int) int {
+		return 0
+	})
+	defer cancel()
+
+	if infos := Coroutines(); len(infos) != 0 {
+		t.Errorf("Expected no coroutines to be registered, got %d", len(infos))
+	}
+}
+
+func TestRegistryHandler(t *testing.T) {
+	disable := EnableRegistry()
+	defer disable()
+
+	resume, cancel := New(func(yield func(int) int, suspend func() int) int {
+		defer func() { _ = recover() }()
+		suspend()
+		return 0
+	}, WithName("workflow"))
+	defer cancel()
+	resume(0)
+
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/debug/coro", nil))
+	body, _ := io.ReadAll(rec.Body)
+
+	for _, want := range []string{
+		`coroutine `,
+		`"workflow" [suspended, 1 resumes, last yield `,
+		"TestRegistryHandler",
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("Expected dump to contain '%s', got '%s'", want, body)
+		}
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("Expected plain text, got '%s'", ct)
+	}
+}
+
+func TestStatusString(t *testing.T) {
+	if s := Status(42).String(); s != "Status(42)" {
+		t.Errorf("Expected 'Status(42)', got '%s'", s)
+	}
+}
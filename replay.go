@@ -0,0 +1,153 @@
+package coro
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"slices"
+
+	"github.com/webriots/coro/internal/panics"
+)
+
+// ErrNondeterministic is wrapped by the error of Replay when a
+// coroutine does not reproduce the outputs it recorded.
+var ErrNondeterministic = errors.New("coro: nondeterministic replay")
+
+// Recording is the history of a replayable coroutine: the encoded
+// value passed to each resume, and a checksum of the output and
+// running state each resume returned. A Recording can itself be
+// serialized, for example with encoding/json, to persist the
+// coroutine.
+type Recording struct {
+	Inputs    [][]byte
+	Checksums []uint64
+}
+
+// Replayable is a coroutine whose state can be saved and restored.
+// A coroutine's stack cannot be serialized, but a deterministic one
+// is fully determined by the values it is resumed with, so a
+// Replayable records them, and Replay reconstructs the coroutine by
+// resuming a new one with the same values.
+//
+// For replay to be faithful, the coroutine function must be
+// deterministic: it must behave the same given the same inputs, and
+// leave side effects to the caller of Resume. Checksums of the
+// recorded outputs let Replay detect a function that does not. The
+// checksums are taken over the codec's encoding of each output, so
+// the codec must encode equal outputs identically; see Codec.
+//
+// A Replayable must not be used from multiple goroutines at once.
+type Replayable[In, Out any] struct {
+	resume func(In) (Out, bool)
+	cancel func()
+	codec  Codec
+	rec    Recording
+	done   bool
+}
+
+// NewReplayable creates a replayable coroutine running fn, using codec
+// to encode its inputs and outputs. Options are passed through to New.
+func NewReplayable[In, Out any](
+	fn func(yield func(Out) In, suspend func() In) Out,
+	codec Codec,
+	opts ...Option,
+) *Replayable[In, Out] {
+	r := &Replayable[In, Out]{codec: codec}
+	r.resume, r.cancel = New(fn, opts...)
+	return r
+}
+
+// Replay reconstructs a replayable coroutine running fn from rec, by
+// resuming it with each recorded input in turn and discarding its
+// outputs. If an output does not match its recorded checksum, Replay
+// cancels the coroutine and returns an error wrapping
+// ErrNondeterministic. Options are passed through to New.
+func Replay[In, Out any](
+	fn func(yield func(Out) In, suspend func() In) Out,
+	codec Codec,
+	rec Recording,
+	opts ...Option,
+) (*Replayable[In, Out], error) {
+	if len(rec.Inputs) != len(rec.Checksums) {
+		return nil, fmt.Errorf("coro: recording has %d inputs but %d checksums", len(rec.Inputs), len(rec.Checksums))
+	}
+	r := NewReplayable(fn, codec, opts...)
+	for i, data := range rec.Inputs {
+		var in In
+		err := codec.Unmarshal(data, &in)
+		if err == nil && r.done {
+			err = fmt.Errorf("%w: coroutine finished after %d of %d resumes", ErrNondeterministic, i, len(rec.Inputs))
+		}
+		if err == nil {
+			_, _, err = r.Resume(in)
+		}
+		if err == nil && r.rec.Checksums[i] != rec.Checksums[i] {
+			err = fmt.Errorf("%w: output %d does not match its checksum", ErrNondeterministic, i)
+		}
+		if err != nil {
+			r.Cancel()
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// Resume resumes the coroutine with in, like the resume function
+// returned by New, recording in and a checksum of the result. Once the
+// coroutine has finished, Resume returns the zero value and false
+// without resuming or recording anything. It returns an error, without
+// resuming, if in cannot be encoded, or after resuming, if the output
+// cannot be; in that case the input is still recorded, but the
+// recording cannot be replayed.
+func (r *Replayable[In, Out]) Resume(in In) (Out, bool, error) {
+	if r.done {
+		var zero Out
+		return zero, false, nil
+	}
+	data, err := r.codec.Marshal(in)
+	if err != nil {
+		var zero Out
+		return zero, true, fmt.Errorf("coro: encoding input: %w", err)
+	}
+	out, running := r.resume(in)
+	r.done = !running
+	sum, err := r.checksum(out, running)
+	r.rec.Inputs = append(r.rec.Inputs, data)
+	r.rec.Checksums = append(r.rec.Checksums, sum)
+	if err != nil {
+		return out, running, fmt.Errorf("coro: encoding output: %w", err)
+	}
+	return out, running, nil
+}
+
+// Cancel cancels the coroutine, like the cancel function returned by
+// New, absorbing the cancellation if the coroutine does not recover
+// from it.
+func (r *Replayable[In, Out]) Cancel() {
+	r.done = true
+	panics.Cancel(r.cancel)
+}
+
+// Recording returns a copy of the coroutine's history so far.
+func (r *Replayable[In, Out]) Recording() Recording {
+	return Recording{
+		Inputs:    slices.Clone(r.rec.Inputs),
+		Checksums: slices.Clone(r.rec.Checksums),
+	}
+}
+
+// checksum returns the FNV-1a hash of an output and running state.
+func (r *Replayable[In, Out]) checksum(out Out, running bool) (uint64, error) {
+	data, err := r.codec.Marshal(out)
+	if err != nil {
+		return 0, err
+	}
+	h := fnv.New64a()
+	h.Write(data)
+	if running {
+		h.Write([]byte{1})
+	} else {
+		h.Write([]byte{0})
+	}
+	return h.Sum64(), nil
+}
@@ -0,0 +1,168 @@
+package coro
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// accumulate sums its inputs, yielding the running total, until it is
+// resumed with a negative number.
+func accumulate(yield func(int) int, suspend func() int) int {
+	total := 0
+	for {
+		n := yield(total)
+		if n < 0 {
+			return total
+		}
+		total += n
+	}
+}
+
+func TestReplayable(t *testing.T) {
+	for name, codec := range map[string]Codec{"json": JSONCodec, "gob": GobCodec} {
+		r := NewReplayable(accumulate, codec)
+		for _, n := range []int{0, 1, 2, 3} {
+			if _, _, err := r.Resume(n); err != nil {
+				t.Fatalf("%s: expected no error, got %v", name, err)
+			}
+		}
+
+		// Persist the recording, then restore the coroutine from it.
+		data, err := json.Marshal(r.Recording())
+		if err != nil {
+			t.Fatalf("%s: expected no error, got %v", name, err)
+		}
+		r.Cancel()
+		var rec Recording
+		if err := json.Unmarshal(data, &rec); err != nil {
+			t.Fatalf("%s: expected no error, got %v", name, err)
+		}
+		restored, err := Replay(accumulate, codec, rec)
+		if err != nil {
+			t.Fatalf("%s: expected no error, got %v", name, err)
+		}
+
+		out, running, err := restored.Resume(4)
+		if out != 10 || !running || err != nil {
+			t.Errorf("%s: expected 10 from the restored coroutine, got %d, %v, %v", name, out, running, err)
+		}
+		out, running, _ = restored.Resume(-1)
+		if out != 10 || running {
+			t.Errorf("%s: expected the restored coroutine to finish with 10, got %d, %v", name, out, running)
+		}
+		if n := len(restored.Recording().Inputs); n != 6 {
+			t.Errorf("%s: expected 6 recorded inputs, got %d", name, n)
+		}
+	}
+}
+
+func TestReplayResumePastEnd(t *testing.T) {
+	r := NewReplayable(accumulate, JSONCodec)
+	r.Resume(0)
+	r.Resume(5)
+	r.Resume(-1)
+	out, running, err := r.Resume(1)
+	if out != 0 || running || err != nil {
+		t.Errorf("Expected a finished coroutine to return 0, false, got %d, %v, %v", out, running, err)
+	}
+
+	rec := r.Recording()
+	if len(rec.Inputs) != 3 || len(rec.Checksums) != 3 {
+		t.Errorf("Expected 3 recorded resumes, got %d inputs and %d checksums", len(rec.Inputs), len(rec.Checksums))
+	}
+	if _, err := Replay(accumulate, JSONCodec, rec); err != nil {
+		t.Errorf("Expected the recording to replay, got %v", err)
+	}
+}
+
+func TestReplayNondeterministic(t *testing.T) {
+	r := NewReplayable(accumulate, JSONCodec)
+	r.Resume(0)
+	r.Resume(5)
+	rec := r.Recording()
+
+	var unwound bool
+	doubled := func(yield func(int) int, suspend func() int) int {
+		defer func() { unwound = true }()
+		total := 0
+		for {
+			total += 2 * yield(total)
+		}
+	}
+	if _, err := Replay(doubled, JSONCodec, rec); !errors.Is(err, ErrNondeterministic) {
+		t.Errorf("Expected ErrNondeterministic, got %v", err)
+	}
+	if !unwound {
+		t.Error("Expected the replayed coroutine to be canceled")
+	}
+
+	short := func(yield func(int) int, suspend func() int) int {
+		return 0
+	}
+	r = NewReplayable(short, JSONCodec)
+	r.Resume(0)
+	rec = r.Recording()
+	rec.Inputs = append(rec.Inputs, rec.Inputs[0])
+	rec.Checksums = append(rec.Checksums, rec.Checksums[0])
+	if _, err := Replay(short, JSONCodec, rec); !errors.Is(err, ErrNondeterministic) {
+		t.Errorf("Expected ErrNondeterministic for a shorter run, got %v", err)
+	}
+}
+
+func TestReplayMapOutputs(t *testing.T) {
+	// Counting words yields maps, whose JSON encoding is deterministic
+	// because map keys are sorted, so replay never reports spurious
+	// nondeterminism.
+	count := func(yield func(map[string]int) string, suspend func() string) map[string]int {
+		counts := make(map[string]int)
+		for word := yield(counts); word != ""; word = yield(counts) {
+			counts[word]++
+		}
+		return counts
+	}
+	words := strings.Fields("a b c d e f g h i j k l m n o p a b c")
+	for range 20 {
+		r := NewReplayable(count, JSONCodec)
+		for _, word := range words {
+			if _, _, err := r.Resume(word); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+		}
+		replayed, err := Replay(count, JSONCodec, r.Recording())
+		if err != nil {
+			t.Fatalf("Expected a deterministic replay, got %v", err)
+		}
+		r.Cancel()
+		replayed.Cancel()
+	}
+}
+
+func TestReplayInvalidRecording(t *testing.T) {
+	if _, err := Replay(accumulate, JSONCodec, Recording{Inputs: [][]byte{[]byte("1")}}); err == nil {
+		t.Error("Expected an error for a recording without checksums")
+	}
+	rec := Recording{Inputs: [][]byte{[]byte("x")}, Checksums: []uint64{0}}
+	if _, err := Replay(accumulate, JSONCodec, rec); err == nil {
+		t.Error("Expected an error for an undecodable input")
+	}
+}
+
+func TestReplayableEncodingErrors(t *testing.T) {
+	r := NewReplayable(func(yield func(func()) any, suspend func() any) func() {
+		yield(func() {})
+		return nil
+	}, JSONCodec)
+	defer r.Cancel()
+
+	if _, _, err := r.Resume(func() {}); err == nil {
+		t.Error("Expected an error encoding the input")
+	}
+	if len(r.Recording().Inputs) != 0 {
+		t.Error("Expected an unencodable input not to be recorded")
+	}
+	if _, running, err := r.Resume(nil); err == nil || !running {
+		t.Errorf("Expected an error encoding the output, got %v", err)
+	}
+}
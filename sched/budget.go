@@ -0,0 +1,115 @@
+package sched
+
+import (
+	"time"
+
+	"github.com/webriots/coro"
+)
+
+// Budget bounds how long a task runs each time it is resumed before
+// Checkpoint yields on its behalf. A task only gives up control at
+// its own yield points, so a long computation should call Checkpoint
+// regularly; with a budget, most of those calls return immediately
+// and one yields once the budget is spent. The zero Budget is
+// unlimited.
+type Budget struct {
+	// Steps is the number of Checkpoint calls per resume, or 0 for no
+	// limit.
+	Steps int
+
+	// Slice is the wall time per resume, or 0 for no limit. To keep
+	// Checkpoint cheap, the clock is only read every 16 checkpoints,
+	// so a task may overrun its slice by up to 15 of them.
+	Slice time.Duration
+
+	// Clock measures Slice. It defaults to coro.SystemClock.
+	Clock coro.Clock
+}
+
+// clockInterval is how often, in checkpoints, Checkpoint reads the
+// clock of a budget with a time slice, since reading it costs far
+// more than counting a step.
+const clockInterval = 16
+
+// meter tracks the budget a task has spent since it was resumed.
+type meter struct {
+	budget Budget
+	steps  int
+	start  time.Time
+}
+
+// set replaces the budget and starts spending it afresh.
+func (m *meter) set(b Budget) {
+	if b.Clock == nil {
+		b.Clock = coro.SystemClock
+	}
+	m.budget = b
+	m.reset()
+}
+
+// reset starts a new slice, when the task is resumed.
+func (m *meter) reset() {
+	m.steps = 0
+	if m.budget.Slice > 0 {
+		m.start = m.budget.Clock.Now()
+	}
+}
+
+// spend counts a checkpoint and reports whether the budget is
+// exhausted.
+func (m *meter) spend() bool {
+	b := &m.budget
+	if b.Steps == 0 && b.Slice == 0 {
+		return false
+	}
+	m.steps++
+	if b.Steps > 0 && m.steps >= b.Steps {
+		return true
+	}
+	return b.Slice > 0 && m.steps%clockInterval == 0 && b.Clock.Now().Sub(m.start) >= b.Slice
+}
+
+// SetBudget sets the budget of t. It may be called before t first
+// runs, to set its budget at spawn, or by t itself, in which case the
+// new budget starts now.
+func (t *Task) SetBudget(b Budget) {
+	t.meter.set(b)
+}
+
+// Budget returns the budget of t.
+func (t *Task) Budget() Budget {
+	return t.meter.budget
+}
+
+// Checkpoint marks a point at which t may be preempted. If t's budget
+// for this resume is exhausted, Checkpoint yields, letting the other
+// runnable tasks run; otherwise it returns immediately. It must only
+// be called by t itself.
+func (t *Task) Checkpoint() {
+	if t.meter.spend() {
+		t.Yield()
+	}
+}
+
+// SetBudget sets the budget of t. It may be called before t first
+// runs, to set its budget at spawn, or by t itself, in which case the
+// new budget starts now.
+func (t *PoolTask) SetBudget(b Budget) {
+	t.meter.set(b)
+}
+
+// Budget returns the budget of t. It must only be called by t itself,
+// or before t first runs.
+func (t *PoolTask) Budget() Budget {
+	return t.meter.budget
+}
+
+// Checkpoint marks a point at which t may be preempted. If t's budget
+// for this resume is exhausted, Checkpoint yields, letting other tasks
+// run; otherwise it returns immediately. It must only be called by t
+// itself.
+func (t *PoolTask) Checkpoint() {
+	if t.meter.spend() {
+		t.Yield()
+	}
+}
@@ -0,0 +1,107 @@
+package sched
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/webriots/coro"
+)
+
+// spinner returns a task body that records its name in trace and
+// calls Checkpoint n times, advancing clock by tick each time if it
+// is not nil.
+func spinner(trace *[]string, name string, n int, clock *coro.ManualClock, tick time.Duration) func(*Task) {
+	return func(t *Task) {
+		for range n {
+			*trace = append(*trace, name)
+			if clock != nil {
+				clock.Advance(tick)
+			}
+			t.Checkpoint()
+		}
+	}
+}
+
+func TestBudgetSteps(t *testing.T) {
+	s := New()
+	var trace []string
+	s.Spawn(spinner(&trace, "a", 5, nil, 0)).SetBudget(Budget{Steps: 2})
+	s.Spawn(spinner(&trace, "b", 3, nil, 0)).SetBudget(Budget{Steps: 3})
+	s.Run()
+
+	expected := []string{"a", "a", "b", "b", "b", "a", "a", "a"}
+	if !reflect.DeepEqual(trace, expected) {
+		t.Errorf("Expected %v, got %v", expected, trace)
+	}
+}
+
+func TestBudgetSlice(t *testing.T) {
+	clock := coro.NewManualClock(time.Unix(0, 0))
+	s := New()
+	var trace []string
+	a := s.Spawn(spinner(&trace, "a", 40, clock, time.Millisecond))
+	a.SetBudget(Budget{Slice: 20 * time.Millisecond, Clock: clock})
+	s.Spawn(spinner(&trace, "b", 1, clock, 0))
+	s.Run()
+
+	// The clock is read every 16 checkpoints, so a first notices that
+	// its slice is spent at the 32nd.
+	if len(trace) != 41 || trace[32] != "b" {
+		t.Errorf("Expected b to run after 32 steps of a, got %v", trace)
+	}
+	if a.Budget().Slice != 20*time.Millisecond {
+		t.Errorf("Expected the budget to be kept, got %v", a.Budget())
+	}
+}
+
+func TestBudgetUnlimited(t *testing.T) {
+	s := New()
+	var trace []string
+	s.Spawn(spinner(&trace, "a", 3, nil, 0))
+	s.Spawn(spinner(&trace, "b", 1, nil, 0))
+	s.Run()
+
+	if expected := []string{"a", "a", "a", "b"}; !reflect.DeepEqual(trace, expected) {
+		t.Errorf("Expected %v, got %v", expected, trace)
+	}
+	if (Budget{}).Clock != nil || s.Spawn(func(*Task) {}).Budget() != (Budget{}) {
+		t.Error("Expected tasks to start with an unlimited budget")
+	}
+}
+
+func TestBudgetPool(t *testing.T) {
+	p := NewPool(1)
+	defer p.Close()
+
+	var trace []string
+	started := make(chan struct{})
+	p.Spawn(func(t *PoolTask) {
+		<-started
+		t.SetBudget(Budget{Steps: 2})
+		for range 4 {
+			trace = append(trace, "a")
+			t.Checkpoint()
+		}
+	})
+	p.Spawn(func(t *PoolTask) {
+		trace = append(trace, "b")
+	})
+	close(started)
+	p.Wait()
+
+	if expected := []string{"a", "a", "b", "a", "a"}; !reflect.DeepEqual(trace, expected) {
+		t.Errorf("Expected %v, got %v", expected, trace)
+	}
+}
+
+func BenchmarkCheckpoint(b *testing.B) {
+	s := New()
+	s.Spawn(func(t *Task) {
+		t.SetBudget(Budget{Steps: 1 << 10, Slice: time.Millisecond})
+		for range b.N {
+			t.Checkpoint()
+		}
+	})
+	s.Run()
+}
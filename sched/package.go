@@ -0,0 +1,32 @@
+// Package sched runs many coroutines cooperatively on a scheduler.
+// Each task is a coroutine that runs until it yields or parks; the
+// scheduler then resumes the next runnable task. Because tasks only
+// switch at those points, a scheduler runs them deterministically
+// without goroutines or locks, and higher-level abstractions such as
+// actors are built on parking a task and waking it again.
+//
+// The order in which runnable tasks run is set by a Policy: FIFO by
+// default, or by priority, earliest deadline first, or weighted fair
+// sharing, with each task's priority, deadline and weight adjustable
+// while it runs.
+//
+// A task with a Budget is preempted at its calls to Checkpoint once it
+// has run for a number of steps or a slice of wall time, so that long
+// computations cannot monopolize the scheduler.
+//
+// Tasks that share state synchronize with Mutex, RWMutex, Semaphore,
+// Cond and WaitGroup, which park a waiting task rather than blocking
+// the goroutine that runs every task. Waiters are served in FIFO
+// order, and Run reports a deadlock when every unfinished task is
+// blocked on one of them.
+//
+// A Scheduler runs every task on one goroutine. To use more cores, a
+// Pool runs tasks on several worker goroutines with per-worker deques
+// and work stealing, while still resuming each task from only one
+// goroutine at a time.
+//
+// A panic in a task does not crash the scheduler: it is captured with
+// its stack trace and becomes the task's error, which a Supervisor
+// uses to restart the task and its siblings according to a restart
+// strategy.
+package sched
@@ -0,0 +1,281 @@
+package sched
+
+import (
+	"container/heap"
+	"time"
+)
+
+// Policy decides the order in which a scheduler runs its runnable
+// tasks. A scheduler calls Push when a task becomes runnable and Pop
+// to choose the next task to run, so a task is pushed at most once
+// until it is popped again. A task that finishes while it is queued
+// may still be returned by Pop, and is then skipped.
+//
+// Update is called when the priority, deadline or weight of a task
+// changes while it is queued, so that the policy can reorder it.
+type Policy interface {
+	Push(t *Task)
+	Pop() *Task
+	Update(t *Task)
+}
+
+// Option configures a scheduler.
+type Option func(*Scheduler)
+
+// WithPolicy sets the policy that orders the scheduler's runnable
+// tasks. The default is FIFO.
+func WithPolicy(p Policy) Option {
+	return func(s *Scheduler) {
+		s.policy = p
+	}
+}
+
+// FIFO is a policy that runs tasks in the order they became
+// runnable, ignoring their priorities, deadlines and weights.
+type FIFO struct {
+	queue []*Task
+}
+
+// NewFIFO returns a FIFO policy.
+func NewFIFO() *FIFO {
+	return &FIFO{}
+}
+
+// Push adds t at the back of the queue.
+func (f *FIFO) Push(t *Task) {
+	f.queue = append(f.queue, t)
+}
+
+// Pop removes the task at the front of the queue, or returns nil.
+func (f *FIFO) Pop() *Task {
+	if len(f.queue) == 0 {
+		return nil
+	}
+	t := f.queue[0]
+	f.queue[0] = nil
+	f.queue = f.queue[1:]
+	return t
+}
+
+// Update does nothing.
+func (f *FIFO) Update(*Task) {}
+
+// PriorityQueue is a policy that runs the runnable task with the
+// highest priority, and tasks of equal priority in the order they
+// became runnable. Since tasks only switch when they yield or park, a
+// high-priority task preempts lower-priority ones at their next yield.
+type PriorityQueue struct {
+	h taskHeap
+}
+
+// NewPriorityQueue returns a priority policy.
+func NewPriorityQueue() *PriorityQueue {
+	p := &PriorityQueue{}
+	p.h.init(func(a, b *Task) bool { return a.priority > b.priority })
+	return p
+}
+
+// Push adds t to the queue.
+func (p *PriorityQueue) Push(t *Task) { p.h.push(t) }
+
+// Pop removes the task with the highest priority, or returns nil.
+func (p *PriorityQueue) Pop() *Task { return p.h.pop() }
+
+// Update reorders t after its priority changed.
+func (p *PriorityQueue) Update(t *Task) { p.h.update(t) }
+
+// EDF is an earliest-deadline-first policy: it runs the runnable task
+// whose deadline is earliest. Tasks without a deadline run after every
+// task with one, and ties are broken by priority and then by the
+// order the tasks became runnable.
+type EDF struct {
+	h taskHeap
+}
+
+// NewEDF returns an earliest-deadline-first policy.
+func NewEDF() *EDF {
+	e := &EDF{}
+	e.h.init(func(a, b *Task) bool {
+		switch {
+		case a.deadline.IsZero() != b.deadline.IsZero():
+			return b.deadline.IsZero()
+		case !a.deadline.Equal(b.deadline):
+			return a.deadline.Before(b.deadline)
+		}
+		return a.priority > b.priority
+	})
+	return e
+}
+
+// Push adds t to the queue.
+func (e *EDF) Push(t *Task) { e.h.push(t) }
+
+// Pop removes the task with the earliest deadline, or returns nil.
+func (e *EDF) Pop() *Task { return e.h.pop() }
+
+// Update reorders t after its deadline or priority changed.
+func (e *EDF) Update(t *Task) { e.h.update(t) }
+
+// WeightedFair is a policy that shares the scheduler between tasks in
+// proportion to their weights. Each time a task is run, its virtual
+// runtime advances by the inverse of its weight, and the task with the
+// least virtual runtime runs next, so a task of weight 2 runs twice
+// as many steps as a task of weight 1 while both are runnable. A task
+// that becomes runnable after waiting starts no further behind than
+// the task run last, so waiting does not earn it a burst of steps.
+type WeightedFair struct {
+	h   taskHeap
+	now float64
+}
+
+// NewWeightedFair returns a weighted-fair policy.
+func NewWeightedFair() *WeightedFair {
+	w := &WeightedFair{}
+	w.h.init(func(a, b *Task) bool { return a.vruntime < b.vruntime })
+	return w
+}
+
+// Push adds t to the queue.
+func (w *WeightedFair) Push(t *Task) {
+	t.vruntime = max(t.vruntime, w.now)
+	w.h.push(t)
+}
+
+// Pop removes the task with the least virtual runtime and charges it
+// for one step, or returns nil.
+func (w *WeightedFair) Pop() *Task {
+	t := w.h.pop()
+	if t != nil {
+		w.now = t.vruntime
+		t.vruntime += 1 / float64(t.Weight())
+	}
+	return t
+}
+
+// Update does nothing: a new weight applies from the task's next step.
+func (w *WeightedFair) Update(*Task) {}
+
+// SetPriority sets the priority of t, which orders it under the
+// PriorityQueue and EDF policies. Higher priorities run first, and
+// tasks start with a priority of 0. It may be called before t first
+// runs, to set its priority at spawn, or at any time after.
+func (t *Task) SetPriority(priority int) {
+	t.priority = priority
+	t.update()
+}
+
+// Priority returns the priority of t.
+func (t *Task) Priority() int {
+	return t.priority
+}
+
+// SetDeadline sets the deadline of t, which orders it under the EDF
+// policy. The zero time means no deadline.
+func (t *Task) SetDeadline(deadline time.Time) {
+	t.deadline = deadline
+	t.update()
+}
+
+// Deadline returns the deadline of t, or the zero time if it has none.
+func (t *Task) Deadline() time.Time {
+	return t.deadline
+}
+
+// SetWeight sets the weight of t, which determines its share of the
+// scheduler under the WeightedFair policy. Tasks start with a weight
+// of 1. It panics if weight is less than 1.
+func (t *Task) SetWeight(weight int) {
+	if weight < 1 {
+		panic("sched: task weight must be at least 1")
+	}
+	t.weight = weight
+	t.update()
+}
+
+// Weight returns the weight of t.
+func (t *Task) Weight() int {
+	return max(t.weight, 1)
+}
+
+// update tells the scheduler's policy that t's attributes changed, if
+// t is queued.
+func (t *Task) update() {
+	if t.co.State == runnable {
+		t.s.policy.Update(t)
+	}
+}
+
+// taskHeap is a priority queue of tasks ordered by less, and then by
+// the order they were pushed.
+type taskHeap struct {
+	entries []*heapEntry
+	index   map[*Task]*heapEntry
+	less    func(a, b *Task) bool
+	seq     uint64
+}
+
+type heapEntry struct {
+	t     *Task
+	seq   uint64
+	index int
+}
+
+func (h *taskHeap) init(less func(a, b *Task) bool) {
+	h.less = less
+	h.index = make(map[*Task]*heapEntry)
+}
+
+func (h *taskHeap) push(t *Task) {
+	h.seq++
+	e := &heapEntry{t: t, seq: h.seq}
+	h.index[t] = e
+	heap.Push(h, e)
+}
+
+func (h *taskHeap) pop() *Task {
+	if len(h.entries) == 0 {
+		return nil
+	}
+	e := heap.Pop(h).(*heapEntry)
+	delete(h.index, e.t)
+	return e.t
+}
+
+func (h *taskHeap) update(t *Task) {
+	if e, ok := h.index[t]; ok {
+		heap.Fix(h, e.index)
+	}
+}
+
+func (h *taskHeap) Len() int { return len(h.entries) }
+
+func (h *taskHeap) Less(i, j int) bool {
+	a, b := h.entries[i], h.entries[j]
+	if h.less(a.t, b.t) {
+		return true
+	}
+	if h.less(b.t, a.t) {
+		return false
+	}
+	return a.seq < b.seq
+}
+
+func (h *taskHeap) Swap(i, j int) {
+	h.entries[i], h.entries[j] = h.entries[j], h.entries[i]
+	h.entries[i].index = i
+	h.entries[j].index = j
+}
+
+func (h *taskHeap) Push(x any) {
+	e := x.(*heapEntry)
+	e.index = len(h.entries)
+	h.entries = append(h.entries, e)
+}
+
+func (h *taskHeap) Pop() any {
+	n := len(h.entries)
+	e := h.entries[n-1]
+	h.entries[n-1] = nil
+	h.entries = h.entries[:n-1]
+	return e
+}
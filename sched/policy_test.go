@@ -0,0 +1,133 @@
+package sched
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// looper returns a task body that records its name in trace n times,
+// yielding after each.
+func looper(trace *[]string, name string, n int) func(*Task) {
+	return func(t *Task) {
+		for range n {
+			*trace = append(*trace, name)
+			t.Yield()
+		}
+	}
+}
+
+func TestPolicyFIFO(t *testing.T) {
+	s := New(WithPolicy(NewFIFO()))
+	var trace []string
+	s.Spawn(looper(&trace, "a", 2)).SetPriority(-1)
+	s.Spawn(looper(&trace, "b", 2)).SetPriority(1)
+	s.Run()
+
+	if expected := []string{"a", "b", "a", "b"}; !reflect.DeepEqual(trace, expected) {
+		t.Errorf("Expected %v, got %v", expected, trace)
+	}
+}
+
+func TestPolicyPriority(t *testing.T) {
+	s := New(WithPolicy(NewPriorityQueue()))
+	var trace []string
+	s.Spawn(looper(&trace, "loader1", 3))
+	s.Spawn(looper(&trace, "loader2", 3))
+	input := s.Spawn(func(t *Task) {
+		trace = append(trace, "input")
+		t.Park()
+		trace = append(trace, "input")
+	})
+	input.SetPriority(10)
+
+	// The input task runs first despite being spawned last.
+	s.Step()
+	s.Step()
+	s.Step()
+	input.Wake()
+	// Woken, it preempts the loaders at their next yield.
+	s.Run()
+
+	expected := []string{"input", "loader1", "loader2", "input", "loader1", "loader2", "loader1", "loader2"}
+	if !reflect.DeepEqual(trace, expected) {
+		t.Errorf("Expected %v, got %v", expected, trace)
+	}
+}
+
+func TestPolicyPriorityChange(t *testing.T) {
+	s := New(WithPolicy(NewPriorityQueue()))
+	var trace []string
+	a := s.Spawn(looper(&trace, "a", 3))
+	b := s.Spawn(looper(&trace, "b", 3))
+	a.SetPriority(1)
+	s.Step()
+	s.Step()
+	// Raising b's priority while it is queued reorders it.
+	b.SetPriority(2)
+	s.Run()
+
+	expected := []string{"a", "a", "b", "b", "b", "a"}
+	if !reflect.DeepEqual(trace, expected) {
+		t.Errorf("Expected %v, got %v", expected, trace)
+	}
+	if a.Priority() != 1 || b.Priority() != 2 {
+		t.Errorf("Expected priorities 1 and 2, got %d and %d", a.Priority(), b.Priority())
+	}
+}
+
+func TestPolicyEDF(t *testing.T) {
+	s := New(WithPolicy(NewEDF()))
+	base := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	var trace []string
+	s.Spawn(looper(&trace, "none", 1))
+	s.Spawn(looper(&trace, "late", 1)).SetDeadline(base.Add(2 * time.Second))
+	s.Spawn(looper(&trace, "early", 1)).SetDeadline(base.Add(time.Second))
+	tie := s.Spawn(looper(&trace, "tie", 1))
+	tie.SetDeadline(base.Add(2 * time.Second))
+	tie.SetPriority(1)
+	s.Run()
+
+	if expected := []string{"early", "tie", "late", "none"}; !reflect.DeepEqual(trace, expected) {
+		t.Errorf("Expected %v, got %v", expected, trace)
+	}
+	if !tie.Deadline().Equal(base.Add(2 * time.Second)) {
+		t.Errorf("Expected the deadline to be kept, got %v", tie.Deadline())
+	}
+}
+
+func TestPolicyWeightedFair(t *testing.T) {
+	s := New(WithPolicy(NewWeightedFair()))
+	var trace []string
+	s.Spawn(looper(&trace, "a", 4))
+	s.Spawn(looper(&trace, "b", 8)).SetWeight(2)
+	for range 6 {
+		s.Step()
+	}
+	counts := strings.Count(strings.Join(trace, ""), "b")
+	if counts != 4 {
+		t.Errorf("Expected b to run twice as often as a, got %v", trace)
+	}
+
+	// A task that waited does not get to catch up all at once.
+	late := s.Spawn(looper(&trace, "c", 4))
+	trace = nil
+	for range 3 {
+		s.Step()
+	}
+	if strings.Count(strings.Join(trace, ""), "c") > 2 {
+		t.Errorf("Expected a new task to share the scheduler, got %v", trace)
+	}
+	s.Run()
+	if !late.Done() {
+		t.Error("Expected every task to finish")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected panic but got none")
+		}
+	}()
+	late.SetWeight(0)
+}
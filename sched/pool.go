@@ -0,0 +1,390 @@
+package sched
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+
+	"github.com/webriots/coro"
+	"github.com/webriots/coro/internal/task"
+)
+
+// ErrPoolClosed is the error of tasks that had not finished when
+// their pool was closed, wrapped together with coro.ErrCanceled.
+var ErrPoolClosed = errors.New("sched: pool closed")
+
+// globalInterval is how often, in tasks run, a worker takes a task
+// from the global queue before its own deque, so that tasks spawned
+// from outside the pool are not starved by busy workers.
+const globalInterval = 61
+
+// Pool runs tasks on a fixed number of worker goroutines. Each worker
+// has its own deque of runnable tasks: tasks a worker spawns go to the
+// bottom of its deque and it takes tasks from there, while idle
+// workers steal from the top of the others' deques. Tasks spawned from
+// outside the pool, woken by Wake, or that yield go to a global queue,
+// except that a task woken while it was parking stays on its worker.
+//
+// A task is only ever resumed by one worker at a time, but it may be
+// resumed by a different worker each time it runs, so tasks that
+// share state must synchronize as goroutines do. The primitives of
+// this package, such as Mutex, are for tasks of a Scheduler and must
+// not be used by pool tasks.
+type Pool struct {
+	workers []*poolWorker
+	global  deque
+
+	// queued is the number of tasks in the deques and the global
+	// queue, and sleepers the number of workers waiting for one.
+	queued   atomic.Int64
+	sleepers atomic.Int64
+
+	mu     sync.Mutex
+	idle   *sync.Cond
+	empty  *sync.Cond
+	live   map[*PoolTask]struct{}
+	errs   []error
+	exited sync.WaitGroup
+
+	// closed is set under mu, but read without it by running workers.
+	closed atomic.Bool
+}
+
+// PoolTask is a coroutine run by a pool.
+type PoolTask struct {
+	p       *Pool
+	resume  func(struct{}) (struct{}, bool)
+	cancel  func()
+	suspend func() struct{}
+
+	// w is the worker running the task, yielded and parking record
+	// why it last suspended, and meter tracks its budget. They are
+	// only used by that worker.
+	w        *poolWorker
+	yielded  bool
+	parking  bool
+	meter    meter
+	finished atomic.Bool
+	err      error
+
+	mu    sync.Mutex
+	state state
+	woken bool
+}
+
+// poolWorker runs the tasks of a pool on one goroutine.
+type poolWorker struct {
+	p     *Pool
+	local deque
+	rand  *rand.Rand
+	ticks int
+}
+
+// NewPool starts a pool with n worker goroutines, which run until the
+// pool is closed. It panics if n is less than 1.
+func NewPool(n int) *Pool {
+	if n < 1 {
+		panic("sched: pool needs at least one worker")
+	}
+	p := &Pool{live: make(map[*PoolTask]struct{})}
+	p.idle = sync.NewCond(&p.mu)
+	p.empty = sync.NewCond(&p.mu)
+	for i := range n {
+		p.workers = append(p.workers, &poolWorker{
+			p:    p,
+			rand: rand.New(rand.NewPCG(uint64(i), uint64(n))),
+		})
+	}
+	p.exited.Add(n)
+	for _, w := range p.workers {
+		go w.loop()
+	}
+	return p
+}
+
+// Spawn creates a task running fn and queues it on the pool's global
+// queue. Options are passed through to coro.New. Spawn may be called
+// from any goroutine, and panics if the pool is closed.
+func (p *Pool) Spawn(fn func(t *PoolTask), opts ...coro.Option) *PoolTask {
+	t := p.create(fn, opts)
+	p.push(&p.global, t)
+	return t
+}
+
+// Spawn creates a task running fn and queues it on the deque of the
+// worker running t, so that tasks fanned out by t tend to stay on its
+// worker unless others are idle. It must only be called by t itself.
+func (t *PoolTask) Spawn(fn func(t *PoolTask), opts ...coro.Option) *PoolTask {
+	c := t.p.create(fn, opts)
+	t.p.push(&t.w.local, c)
+	return c
+}
+
+// create returns a new runnable task of p running fn.
+// It panics before creating the task's coroutine if p is closed.
+func (p *Pool) create(fn func(t *PoolTask), opts []coro.Option) *PoolTask {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed.Load() {
+		panic(ErrPoolClosed)
+	}
+	t := &PoolTask{p: p}
+	t.resume, t.cancel = coro.New(func(_ func(struct{}) struct{}, suspend func() struct{}) struct{} {
+		t.suspend = suspend
+		fn(t)
+		return struct{}{}
+	}, opts...)
+	p.live[t] = struct{}{}
+	return t
+}
+
+// push queues t on d and wakes a sleeping worker to run it.
+func (p *Pool) push(d *deque, t *PoolTask) {
+	d.push(t)
+	p.queued.Add(1)
+	if p.sleepers.Load() > 0 {
+		p.mu.Lock()
+		p.idle.Signal()
+		p.mu.Unlock()
+	}
+}
+
+// Wait blocks until every task spawned so far has finished, and
+// returns the errors of the tasks that panicked since the last call,
+// joined with errors.Join. It must not be called by a task of p.
+func (p *Pool) Wait() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for len(p.live) > 0 && !p.closed.Load() {
+		p.empty.Wait()
+	}
+	err := errors.Join(p.errs...)
+	p.errs = nil
+	return err
+}
+
+// Close stops the workers once they have suspended the tasks they are
+// running, and cancels every task that has not finished, including
+// queued ones that yielded or were preempted. Their errors
+// wrap both coro.ErrCanceled and ErrPoolClosed. It must not be called
+// by a task of p.
+func (p *Pool) Close() {
+	p.mu.Lock()
+	if p.closed.Load() {
+		p.mu.Unlock()
+		return
+	}
+	p.closed.Store(true)
+	p.idle.Broadcast()
+	p.empty.Broadcast()
+	p.mu.Unlock()
+	p.exited.Wait()
+
+	for t := range p.live {
+		t.finish(task.Unwind(t.cancel, fmt.Errorf("%w: %w", coro.ErrCanceled, ErrPoolClosed)))
+	}
+}
+
+// loop runs tasks until the pool is closed, leaving the tasks still
+// queued to be canceled by Close.
+func (w *poolWorker) loop() {
+	defer w.p.exited.Done()
+	for !w.p.closed.Load() {
+		t := w.find()
+		if t == nil {
+			if !w.sleep() {
+				return
+			}
+			continue
+		}
+		w.p.queued.Add(-1)
+		w.run(t)
+	}
+}
+
+// find takes a runnable task from the worker's deque, the global
+// queue or another worker's deque, in that order, or returns nil.
+func (w *poolWorker) find() *PoolTask {
+	w.ticks++
+	if w.ticks%globalInterval == 0 {
+		if t := w.p.global.steal(); t != nil {
+			return t
+		}
+	}
+	if t := w.local.pop(); t != nil {
+		return t
+	}
+	if t := w.p.global.steal(); t != nil {
+		return t
+	}
+	n := len(w.p.workers)
+	for i, off := 0, w.rand.IntN(n); i < n; i++ {
+		if v := w.p.workers[(i+off)%n]; v != w {
+			if t := v.local.steal(); t != nil {
+				return t
+			}
+		}
+	}
+	return nil
+}
+
+// sleep waits until a task may be runnable, and reports whether the
+// pool is still open.
+func (w *poolWorker) sleep() bool {
+	p := w.p
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.sleepers.Add(1)
+	for p.queued.Load() == 0 && !p.closed.Load() {
+		p.idle.Wait()
+	}
+	p.sleepers.Add(-1)
+	return !p.closed.Load()
+}
+
+// run resumes t on the worker until it yields, parks or finishes.
+func (w *poolWorker) run(t *PoolTask) {
+	t.mu.Lock()
+	t.state = running
+	t.mu.Unlock()
+	t.w, t.yielded, t.parking = w, false, false
+	t.meter.reset()
+
+	finished, err := task.Step(t.resume)
+	switch {
+	case finished:
+		t.finish(err)
+	case t.yielded:
+		t.mu.Lock()
+		t.state = runnable
+		t.mu.Unlock()
+		w.p.push(&w.p.global, t)
+	case t.parking:
+		t.mu.Lock()
+		if t.woken {
+			t.woken, t.state = false, runnable
+			t.mu.Unlock()
+			w.p.push(&w.local, t)
+			return
+		}
+		t.state = parked
+		t.mu.Unlock()
+	}
+}
+
+// finish records that t has finished with err.
+func (t *PoolTask) finish(err error) {
+	t.mu.Lock()
+	t.state, t.err = done, err
+	t.mu.Unlock()
+	t.finished.Store(true)
+
+	p := t.p
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.live, t)
+	if err != nil && !errors.Is(err, coro.ErrCanceled) {
+		p.errs = append(p.errs, err)
+	}
+	if len(p.live) == 0 {
+		p.empty.Broadcast()
+	}
+}
+
+// Yield lets other tasks run before t continues, possibly on another
+// worker. It must only be called by t itself.
+func (t *PoolTask) Yield() {
+	t.yielded = true
+	t.suspend()
+}
+
+// Park suspends t until it is woken by Wake. If t was woken since it
+// last parked, Park returns immediately. It must only be called by t
+// itself.
+func (t *PoolTask) Park() {
+	t.mu.Lock()
+	if t.woken {
+		t.woken = false
+		t.mu.Unlock()
+		return
+	}
+	t.mu.Unlock()
+	t.parking = true
+	t.suspend()
+}
+
+// Wake makes a parked task runnable again. Waking a task that is not
+// parked makes its next Park return immediately, so wakeups are not
+// lost; waking a finished task does nothing. Wake may be called from
+// any goroutine.
+func (t *PoolTask) Wake() {
+	t.mu.Lock()
+	switch t.state {
+	case parked:
+		t.state = runnable
+		t.mu.Unlock()
+		t.p.push(&t.p.global, t)
+		return
+	case runnable, running:
+		t.woken = true
+	}
+	t.mu.Unlock()
+}
+
+// Done reports whether t has finished. It may be called from any
+// goroutine.
+func (t *PoolTask) Done() bool {
+	return t.finished.Load()
+}
+
+// Err returns nil if t returned normally, the error of its panic, or
+// an error wrapping coro.ErrCanceled if it was canceled by Close. It
+// returns nil until t has finished.
+func (t *PoolTask) Err() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.err
+}
+
+// deque is a double-ended queue of runnable tasks. Its owner pushes
+// and pops at the bottom, while other workers steal from the top.
+type deque struct {
+	mu    sync.Mutex
+	tasks []*PoolTask
+}
+
+// push adds t at the bottom of d.
+func (d *deque) push(t *PoolTask) {
+	d.mu.Lock()
+	d.tasks = append(d.tasks, t)
+	d.mu.Unlock()
+}
+
+// pop removes the task at the bottom of d, or returns nil.
+func (d *deque) pop() *PoolTask {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	n := len(d.tasks)
+	if n == 0 {
+		return nil
+	}
+	t := d.tasks[n-1]
+	d.tasks[n-1] = nil
+	d.tasks = d.tasks[:n-1]
+	return t
+}
+
+// steal removes the task at the top of d, or returns nil.
+func (d *deque) steal() *PoolTask {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.tasks) == 0 {
+		return nil
+	}
+	t := d.tasks[0]
+	d.tasks[0] = nil
+	d.tasks = d.tasks[1:]
+	return t
+}
@@ -0,0 +1,266 @@
+package sched
+
+import (
+	"errors"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/webriots/coro"
+)
+
+func TestPool(t *testing.T) {
+	p := NewPool(4)
+	defer p.Close()
+
+	var sum atomic.Int64
+	for i := range 1000 {
+		p.Spawn(func(t *PoolTask) {
+			for range 3 {
+				t.Yield()
+			}
+			sum.Add(int64(i))
+		})
+	}
+	if err := p.Wait(); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+	if expected := int64(999 * 1000 / 2); sum.Load() != expected {
+		t.Errorf("Expected %d, got %d", expected, sum.Load())
+	}
+}
+
+func TestPoolFanOut(t *testing.T) {
+	p := NewPool(4)
+	defer p.Close()
+
+	var leaves atomic.Int64
+	var tree func(depth int) func(*PoolTask)
+	tree = func(depth int) func(*PoolTask) {
+		return func(t *PoolTask) {
+			if depth == 0 {
+				leaves.Add(1)
+				return
+			}
+			t.Spawn(tree(depth - 1))
+			t.Spawn(tree(depth - 1))
+		}
+	}
+	p.Spawn(tree(10))
+	p.Wait()
+	if leaves.Load() != 1024 {
+		t.Errorf("Expected 1024 leaves, got %d", leaves.Load())
+	}
+}
+
+func TestPoolParkWake(t *testing.T) {
+	p := NewPool(2)
+	defer p.Close()
+
+	parked := make(chan struct{})
+	var woken atomic.Bool
+	task := p.Spawn(func(t *PoolTask) {
+		close(parked)
+		t.Park()
+		woken.Store(true)
+	})
+	<-parked
+	go task.Wake()
+	p.Wait()
+	if !woken.Load() || !task.Done() || task.Err() != nil {
+		t.Errorf("Expected the task to be woken and finish, got %v", task.Err())
+	}
+}
+
+func TestPoolPingPong(t *testing.T) {
+	p := NewPool(4)
+	defer p.Close()
+
+	// Two tasks hand a counter back and forth, each parking until the
+	// other wakes it. Only one of them touches the counter at a time.
+	var tasks [2]*PoolTask
+	var mu sync.Mutex
+	var turn, count int
+	ready := make(chan struct{})
+	for i := range tasks {
+		tasks[i] = p.Spawn(func(t *PoolTask) {
+			<-ready
+			for {
+				mu.Lock()
+				if count == 1000 {
+					mu.Unlock()
+					tasks[1-i].Wake()
+					return
+				}
+				if turn == i {
+					count++
+					turn = 1 - i
+					mu.Unlock()
+					tasks[1-i].Wake()
+					continue
+				}
+				mu.Unlock()
+				t.Park()
+			}
+		})
+	}
+	close(ready)
+	p.Wait()
+	if count != 1000 {
+		t.Errorf("Expected 1000, got %d", count)
+	}
+}
+
+func TestPoolPanic(t *testing.T) {
+	p := NewPool(2)
+	defer p.Close()
+
+	errBoom := errors.New("boom")
+	task := p.Spawn(func(*PoolTask) { panic(errBoom) })
+	p.Spawn(func(*PoolTask) {})
+	if err := p.Wait(); !errors.Is(err, errBoom) {
+		t.Errorf("Expected %v, got %v", errBoom, err)
+	}
+	if !errors.Is(task.Err(), errBoom) {
+		t.Errorf("Expected the task's error to be %v, got %v", errBoom, task.Err())
+	}
+	if err := p.Wait(); err != nil {
+		t.Errorf("Expected errors to be reported once, got %v", err)
+	}
+}
+
+func TestPoolClose(t *testing.T) {
+	p := NewPool(2)
+	parked := make(chan struct{})
+	var unwound atomic.Bool
+	task := p.Spawn(func(t *PoolTask) {
+		defer unwound.Store(true)
+		close(parked)
+		t.Park()
+	})
+	// Tasks that keep yielding, or are preempted by their budget, are
+	// stopped too.
+	spinning := make(chan struct{})
+	spinner := p.Spawn(func(t *PoolTask) {
+		close(spinning)
+		for {
+			t.Yield()
+		}
+	})
+	preempted := p.Spawn(func(t *PoolTask) {
+		t.SetBudget(Budget{Steps: 1})
+		for {
+			t.Checkpoint()
+		}
+	})
+	<-parked
+	<-spinning
+
+	closed := make(chan struct{})
+	go func() {
+		p.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Expected Close to return")
+	}
+
+	if !unwound.Load() || !task.Done() {
+		t.Error("Expected the parked task to be unwound")
+	}
+	for _, task := range []*PoolTask{task, spinner, preempted} {
+		if err := task.Err(); !errors.Is(err, coro.ErrCanceled) || !errors.Is(err, ErrPoolClosed) {
+			t.Errorf("Expected a canceled task, got %v", err)
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected panic but got none")
+		}
+	}()
+	p.Spawn(func(*PoolTask) {})
+}
+
+// work is a CPU-bound unit of work for the fan-out benchmarks.
+func work(seed uint64) uint64 {
+	x := seed
+	for range 2000 {
+		x ^= x << 13
+		x ^= x >> 7
+		x ^= x << 17
+	}
+	return x
+}
+
+const fanOut = 1000
+
+var sink atomic.Uint64
+
+func BenchmarkPoolFanOut(b *testing.B) {
+	p := NewPool(runtime.GOMAXPROCS(0))
+	defer p.Close()
+	for range b.N {
+		p.Spawn(func(t *PoolTask) {
+			for i := range fanOut {
+				t.Spawn(func(*PoolTask) { sink.Add(work(uint64(i))) })
+			}
+		})
+		p.Wait()
+	}
+}
+
+func BenchmarkPoolFanOutYield(b *testing.B) {
+	p := NewPool(runtime.GOMAXPROCS(0))
+	defer p.Close()
+	for range b.N {
+		for i := range fanOut {
+			p.Spawn(func(t *PoolTask) {
+				x := uint64(i)
+				for range 4 {
+					x = work(x)
+					t.Yield()
+				}
+				sink.Add(x)
+			})
+		}
+		p.Wait()
+	}
+}
+
+func BenchmarkGoroutineFanOut(b *testing.B) {
+	for range b.N {
+		var wg sync.WaitGroup
+		for i := range fanOut {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				sink.Add(work(uint64(i)))
+			}()
+		}
+		wg.Wait()
+	}
+}
+
+func BenchmarkGoroutineFanOutYield(b *testing.B) {
+	for range b.N {
+		var wg sync.WaitGroup
+		for i := range fanOut {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				x := uint64(i)
+				for range 4 {
+					x = work(x)
+					runtime.Gosched()
+				}
+				sink.Add(x)
+			}()
+		}
+		wg.Wait()
+	}
+}
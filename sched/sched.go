@@ -0,0 +1,298 @@
+package sched
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/webriots/coro"
+	"github.com/webriots/coro/internal/task"
+)
+
+// ErrDeadlock is wrapped by the error Run panics with when every
+// unfinished task is blocked on a synchronization primitive.
+var ErrDeadlock = errors.New("sched: deadlock")
+
+// Wait is an edge of a wait-for graph: a blocked task, the primitive
+// it waits on, and the task holding that primitive, if known. Only a
+// Mutex, or an RWMutex locked for writing, knows the task holding it.
+type Wait struct {
+	Task   *Task
+	On     any
+	Holder *Task
+}
+
+func (w Wait) String() string {
+	s := fmt.Sprintf("%v waits on %T %p", w.Task, w.On, w.On)
+	if w.Holder != nil {
+		s += fmt.Sprintf(" held by %v", w.Holder)
+	}
+	return s
+}
+
+// DeadlockError reports that every unfinished task of a scheduler is
+// blocked on a synchronization primitive, so none of them can ever
+// run again.
+type DeadlockError struct {
+	// Tasks are the blocked tasks, in the order they blocked.
+	Tasks []*Task
+
+	// Waits is the wait-for graph of the blocked tasks, with an edge
+	// for each task in Tasks.
+	Waits []Wait
+}
+
+// Error describes every edge of the wait-for graph along with the
+// stack trace of the call to Spawn that created the waiting task.
+func (e *DeadlockError) Error() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%v: all %d tasks are blocked", ErrDeadlock, len(e.Tasks))
+	for _, w := range e.Waits {
+		fmt.Fprintf(&sb, "\n\n%v, created at:\n%s", w, w.Task.Stack())
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+func (e *DeadlockError) Unwrap() error {
+	return ErrDeadlock
+}
+
+// deadlock returns the error describing the blocked tasks of s.
+func (s *Scheduler) deadlock() *DeadlockError {
+	e := &DeadlockError{Tasks: slices.Clone(s.blocked)}
+	for _, t := range e.Tasks {
+		w := Wait{Task: t, On: t.on}
+		if h, ok := t.on.(holder); ok {
+			w.Holder = h.holder()
+		}
+		e.Waits = append(e.Waits, w)
+	}
+	return e
+}
+
+// state is the scheduling state of a task.
+type state = task.State
+
+const (
+	runnable = task.Runnable
+	running  = task.Running
+	parked   = task.Parked
+	done     = task.Done
+)
+
+// Scheduler runs tasks on the calling goroutine, one at a time, in
+// the order chosen by its policy, which by default is the order they
+// become runnable. A Scheduler must not be used from multiple
+// goroutines at once.
+type Scheduler struct {
+	policy  Policy
+	live    int
+	blocked []*Task
+	lastID  int
+}
+
+// New returns an empty scheduler.
+func New(opts ...Option) *Scheduler {
+	s := &Scheduler{policy: NewFIFO()}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(s)
+		}
+	}
+	return s
+}
+
+// Task is a coroutine run by a scheduler.
+type Task struct {
+	s     *Scheduler
+	id    int
+	pcs   []uintptr
+	co    task.Task
+	err   error
+	exits []func(error)
+	on    any
+
+	// interruptible is set while t waits in Cond.Wait, where canceling
+	// it sets canceling and wakes it rather than unwinding it at once.
+	interruptible bool
+	canceling     bool
+
+	priority int
+	deadline time.Time
+	weight   int
+	vruntime float64
+	meter    meter
+}
+
+// Spawn creates a task running fn and makes it runnable. The task
+// does not start until the scheduler runs it. Options are passed
+// through to coro.New.
+func (s *Scheduler) Spawn(fn func(t *Task), opts ...coro.Option) *Task {
+	s.lastID++
+	pcs := make([]uintptr, 32)
+	t := &Task{s: s, id: s.lastID, pcs: pcs[:runtime.Callers(2, pcs)]}
+	t.co.Start(func() { fn(t) }, opts)
+	s.live++
+	s.policy.Push(t)
+	return t
+}
+
+// Live returns the number of tasks that have not finished.
+func (s *Scheduler) Live() int {
+	return s.live
+}
+
+// Run runs tasks until none is runnable, either because they have
+// all finished or because the rest are parked.
+//
+// If a task panics and no OnExit callback has been registered for it,
+// Run propagates the panic, wrapped with the task's stack trace. If
+// Run stops with every unfinished task blocked on a Mutex, RWMutex,
+// Semaphore, Cond or WaitGroup, it panics with a *DeadlockError
+// holding their wait-for graph, much as the Go runtime does when every
+// goroutine is asleep. Tasks parked with Park are not considered
+// blocked, since they may be woken from outside the scheduler.
+func (s *Scheduler) Run() {
+	for s.Step() {
+	}
+	if s.live > 0 && len(s.blocked) == s.live {
+		panic(s.deadlock())
+	}
+}
+
+// Step resumes the next runnable task until it yields, parks or
+// finishes, and reports whether there was one.
+func (s *Scheduler) Step() bool {
+	for t := s.policy.Pop(); t != nil; t = s.policy.Pop() {
+		if t.co.State != runnable {
+			continue
+		}
+		t.run()
+		return true
+	}
+	return false
+}
+
+// run resumes t, finishing it if it returns or panics.
+func (t *Task) run() {
+	t.meter.reset()
+	if finished, err := t.co.Step(); finished {
+		t.finish(err)
+	}
+}
+
+// finish records that t has finished with err and notifies its OnExit
+// callbacks. An unobserved panic is propagated.
+func (t *Task) finish(err error) {
+	t.co.State, t.err = done, err
+	t.s.live--
+	for _, fn := range t.exits {
+		fn(err)
+	}
+	if err != nil && len(t.exits) == 0 && !errors.Is(err, coro.ErrCanceled) {
+		panic(err)
+	}
+}
+
+// block records that t is waiting on the synchronization primitive on.
+func (t *Task) block(on any) {
+	t.on = on
+	t.s.blocked = append(t.s.blocked, t)
+}
+
+// unblock records that t is no longer waiting on a primitive.
+func (t *Task) unblock() {
+	t.on = nil
+	if i := slices.Index(t.s.blocked, t); i >= 0 {
+		t.s.blocked = slices.Delete(t.s.blocked, i, i+1)
+	}
+}
+
+// Yield lets the other runnable tasks run before t continues. It must
+// only be called by t itself.
+func (t *Task) Yield() {
+	t.s.policy.Push(t)
+	t.co.Yield()
+}
+
+// Park suspends t until it is woken by Wake. If t was woken since it
+// last parked, Park returns immediately. It must only be called by t
+// itself.
+func (t *Task) Park() {
+	t.co.Park()
+}
+
+// Wake makes a parked task runnable again. Waking a task that is not
+// parked makes its next Park return immediately, so wakeups are not
+// lost; waking a finished task does nothing.
+func (t *Task) Wake() {
+	if t.co.Wake() {
+		t.s.policy.Push(t)
+	}
+}
+
+// Cancel stops t, unwinding its coroutine. When called by t itself,
+// Cancel panics to unwind it, and does not return. A task waiting in
+// Cond.Wait is instead woken to relock the Cond's lock, and unwinds
+// the next time the scheduler runs it.
+func (t *Task) Cancel() {
+	switch {
+	case t.co.State == done || t.canceling:
+		return
+	case t.co.State == running:
+		panic(fmt.Errorf("%w", coro.ErrCanceled))
+	case t.interruptible:
+		t.canceling = true
+		t.Wake()
+		return
+	}
+	t.finish(t.co.Unwind(coro.ErrCanceled))
+}
+
+// ID returns the number of t within its scheduler. Tasks are numbered
+// from 1 in the order they were spawned.
+func (t *Task) ID() int {
+	return t.id
+}
+
+// String returns a description of t for error messages.
+func (t *Task) String() string {
+	return fmt.Sprintf("task %d", t.id)
+}
+
+// Stack returns the stack trace of the call to Spawn that created t.
+func (t *Task) Stack() string {
+	var sb strings.Builder
+	frames := runtime.CallersFrames(t.pcs)
+	for {
+		f, more := frames.Next()
+		fmt.Fprintf(&sb, "%s\n\t%s:%d\n", f.Function, f.File, f.Line)
+		if !more {
+			break
+		}
+	}
+	return sb.String()
+}
+
+// Done reports whether t has finished.
+func (t *Task) Done() bool {
+	return t.co.State == done
+}
+
+// Err returns nil if t returned normally, an error wrapping
+// coro.ErrCanceled if it was canceled, or the error of its panic,
+// which carries the panic's stack trace. It returns nil until t has
+// finished.
+func (t *Task) Err() error {
+	return t.err
+}
+
+// OnExit registers fn to be called with t's error when t finishes.
+// Registering a callback marks panics in t as observed, so they are
+// not propagated by Run.
+func (t *Task) OnExit(fn func(err error)) {
+	t.exits = append(t.exits, fn)
+}
@@ -0,0 +1,185 @@
+package sched
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/webriots/coro"
+)
+
+func TestSchedulerYield(t *testing.T) {
+	s := New()
+	var trace []string
+	for _, name := range []string{"a", "b", "c"} {
+		s.Spawn(func(t *Task) {
+			for i := range 2 {
+				trace = append(trace, name+string(rune('0'+i)))
+				t.Yield()
+			}
+		})
+	}
+
+	if s.Live() != 3 {
+		t.Errorf("Expected 3 live tasks, got %d", s.Live())
+	}
+	s.Run()
+
+	expected := []string{"a0", "b0", "c0", "a1", "b1", "c1"}
+	if !reflect.DeepEqual(trace, expected) {
+		t.Errorf("Expected %v, got %v", expected, trace)
+	}
+	if s.Live() != 0 {
+		t.Errorf("Expected no live tasks, got %d", s.Live())
+	}
+}
+
+func TestSchedulerParkWake(t *testing.T) {
+	s := New()
+	var trace []string
+	waiter := s.Spawn(func(t *Task) {
+		trace = append(trace, "parking")
+		t.Park()
+		trace = append(trace, "woken")
+	})
+
+	s.Run()
+	if waiter.Done() || s.Live() != 1 {
+		t.Error("Expected the parked task to be waiting")
+	}
+
+	s.Spawn(func(t *Task) {
+		trace = append(trace, "waking")
+		waiter.Wake()
+	})
+	s.Run()
+
+	expected := []string{"parking", "waking", "woken"}
+	if !reflect.DeepEqual(trace, expected) {
+		t.Errorf("Expected %v, got %v", expected, trace)
+	}
+	if !waiter.Done() || waiter.Err() != nil {
+		t.Errorf("Expected the task to finish cleanly, got %v", waiter.Err())
+	}
+}
+
+func TestSchedulerWakeBeforePark(t *testing.T) {
+	s := New()
+	var parked bool
+	task := s.Spawn(func(t *Task) {
+		t.Wake()
+		t.Park()
+		parked = true
+	})
+	s.Step()
+	if !parked || !task.Done() {
+		t.Error("Expected an earlier wakeup to keep the task from parking")
+	}
+}
+
+func TestSchedulerStep(t *testing.T) {
+	s := New()
+	if s.Step() {
+		t.Error("Expected no runnable task")
+	}
+	s.Spawn(func(t *Task) { t.Yield() })
+	if !s.Step() || !s.Step() || s.Step() {
+		t.Error("Expected exactly two steps")
+	}
+}
+
+func TestTaskCancel(t *testing.T) {
+	s := New()
+	var unwound bool
+	task := s.Spawn(func(t *Task) {
+		defer func() { unwound = true }()
+		t.Park()
+	})
+	var exitErr error
+	task.OnExit(func(err error) { exitErr = err })
+
+	s.Run()
+	task.Cancel()
+	task.Cancel()
+
+	if !unwound || !task.Done() || s.Live() != 0 {
+		t.Error("Expected the task to be unwound")
+	}
+	if !errors.Is(exitErr, coro.ErrCanceled) || !errors.Is(task.Err(), coro.ErrCanceled) {
+		t.Errorf("Expected ErrCanceled, got %v", exitErr)
+	}
+}
+
+func TestTaskCancelSelf(t *testing.T) {
+	s := New()
+	var after bool
+	task := s.Spawn(func(t *Task) {
+		t.Cancel()
+		after = true
+	})
+	s.Run()
+
+	if after {
+		t.Error("Expected Cancel not to return")
+	}
+	if !errors.Is(task.Err(), coro.ErrCanceled) {
+		t.Errorf("Expected ErrCanceled, got %v", task.Err())
+	}
+}
+
+func TestTaskCancelUnstarted(t *testing.T) {
+	s := New()
+	var ran bool
+	task := s.Spawn(func(t *Task) { ran = true })
+	task.Cancel()
+	s.Run()
+	if ran || !task.Done() {
+		t.Error("Expected the canceled task never to run")
+	}
+}
+
+func TestTaskPanic(t *testing.T) {
+	s := New()
+	task := s.Spawn(func(t *Task) { panic("test panic") })
+	var exitErr error
+	task.OnExit(func(err error) { exitErr = err })
+	next := s.Spawn(func(t *Task) {})
+	s.Run()
+
+	if exitErr == nil || exitErr.Error() != "test panic" || task.Err() != exitErr {
+		t.Errorf("Expected the panic as the task's error, got %v", exitErr)
+	}
+	if _, ok := exitErr.(interface{ ErrorWithStack() string }); !ok {
+		t.Error("Expected the error to carry a stack trace")
+	}
+	if !next.Done() {
+		t.Error("Expected other tasks to keep running")
+	}
+}
+
+func TestTaskPanicUnobserved(t *testing.T) {
+	s := New()
+	s.Spawn(func(t *Task) { panic("test panic") })
+
+	defer func() {
+		r := recover()
+		if err, ok := r.(error); !ok || err.Error() != "test panic" {
+			t.Errorf("Expected the task's panic, got %v", r)
+		}
+	}()
+	s.Run()
+}
+
+func TestTaskIdentity(t *testing.T) {
+	s := New()
+	a := s.Spawn(func(*Task) {})
+	b := s.Spawn(func(*Task) {})
+	if a.ID() != 1 || b.ID() != 2 || b.String() != "task 2" {
+		t.Errorf("Expected tasks 1 and 2, got %d and %v", a.ID(), b)
+	}
+	if stack := a.Stack(); !strings.HasPrefix(stack, "github.com/webriots/coro/sched.TestTaskIdentity\n") {
+		t.Errorf("Expected the stack to start at the call to Spawn, got:\n%s", stack)
+	}
+	s.Run()
+}
@@ -0,0 +1,265 @@
+package sched
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/webriots/coro"
+)
+
+// ErrIntensity is wrapped by the error of a supervisor that gave up
+// because its children failed more often than its restart intensity
+// allows.
+var ErrIntensity = errors.New("sched: supervisor restart intensity exceeded")
+
+// Strategy decides which children a supervisor restarts when one of
+// them fails.
+type Strategy int
+
+const (
+	// OneForOne restarts only the failed child.
+	OneForOne Strategy = iota
+	// OneForAll stops every other child and restarts them all.
+	OneForAll
+	// RestForOne stops the children started after the failed one and
+	// restarts it and them.
+	RestForOne
+)
+
+// String returns the name of the strategy.
+func (s Strategy) String() string {
+	switch s {
+	case OneForOne:
+		return "one-for-one"
+	case OneForAll:
+		return "one-for-all"
+	case RestForOne:
+		return "rest-for-one"
+	}
+	return fmt.Sprintf("Strategy(%d)", int(s))
+}
+
+// Child specifies a child of a supervisor. Run is called in a new task
+// each time the child is started.
+type Child struct {
+	Name string
+	Run  func(t *Task)
+}
+
+// SupervisorOption configures a supervisor.
+type SupervisorOption func(*Supervisor)
+
+// WithIntensity allows a supervisor at most max restarts in any
+// period. One more failure within the period makes the supervisor
+// give up and escalate. The default is 3 restarts in 5 seconds.
+func WithIntensity(max int, period time.Duration) SupervisorOption {
+	return func(sup *Supervisor) {
+		sup.maxRestarts, sup.period = max, period
+	}
+}
+
+// WithClock sets the clock a supervisor measures its restart
+// intensity with. The default is coro.SystemClock.
+func WithClock(clock coro.Clock) SupervisorOption {
+	return func(sup *Supervisor) {
+		sup.clock = clock
+	}
+}
+
+// child is a child of a supervisor and its current task.
+type child struct {
+	spec Child
+	task *Task
+	// live is false once the child has returned normally, or while it
+	// is being stopped by its supervisor.
+	live bool
+}
+
+// Supervisor runs a set of child tasks and restarts them when they
+// panic, according to its strategy. A child that returns or is
+// canceled by someone else is not restarted.
+//
+// If children fail more often than the restart intensity allows, the
+// supervisor escalates: it stops every child and fails with an error
+// wrapping ErrIntensity and the last child's error. A nested
+// supervisor created with SupervisorChild then fails as a child of its
+// parent. Unless a callback has been registered with OnExit, the
+// failure of a top-level supervisor is propagated by the scheduler's
+// Run.
+type Supervisor struct {
+	s           *Scheduler
+	strategy    Strategy
+	maxRestarts int
+	period      time.Duration
+	clock       coro.Clock
+	children    []*child
+	history     []time.Time
+	restarts    int
+	stopped     bool
+	err         error
+	exits       []func(error)
+}
+
+// NewSupervisor starts children on s, in order, under a supervisor
+// using strategy.
+func NewSupervisor(s *Scheduler, strategy Strategy, children []Child, opts ...SupervisorOption) *Supervisor {
+	sup := &Supervisor{
+		s:           s,
+		strategy:    strategy,
+		maxRestarts: 3,
+		period:      5 * time.Second,
+		clock:       coro.SystemClock,
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(sup)
+		}
+	}
+	for _, spec := range children {
+		c := &child{spec: spec}
+		sup.children = append(sup.children, c)
+		sup.start(c)
+	}
+	return sup
+}
+
+// SupervisorChild returns a child that runs a nested supervisor, for
+// building supervisor trees. If the nested supervisor escalates, the
+// child fails with its error; if the child is stopped, so are the
+// nested supervisor's children.
+func SupervisorChild(name string, strategy Strategy, children []Child, opts ...SupervisorOption) Child {
+	return Child{Name: name, Run: func(t *Task) {
+		sup := NewSupervisor(t.s, strategy, children, opts...)
+		defer sup.Stop()
+		sup.OnExit(func(error) { t.Wake() })
+		for !sup.stopped {
+			t.Park()
+		}
+		if sup.err != nil {
+			panic(sup.err)
+		}
+	}}
+}
+
+// start runs c in a new task.
+func (sup *Supervisor) start(c *child) {
+	var opts []coro.Option
+	if c.spec.Name != "" {
+		opts = append(opts, coro.WithName(c.spec.Name))
+	}
+	t := sup.s.Spawn(c.spec.Run, opts...)
+	c.task, c.live = t, true
+	t.OnExit(func(err error) {
+		if c.task == t {
+			sup.exited(c, err)
+		}
+	})
+}
+
+// exited applies the supervisor's policy to a child that finished.
+func (sup *Supervisor) exited(c *child, err error) {
+	if sup.stopped || !c.live {
+		return
+	}
+	if err == nil || errors.Is(err, coro.ErrCanceled) {
+		c.live = false
+		return
+	}
+
+	now := sup.clock.Now()
+	sup.history = append(sup.history, now)
+	sup.history = slices.DeleteFunc(sup.history, func(at time.Time) bool {
+		return now.Sub(at) >= sup.period
+	})
+	if len(sup.history) > sup.maxRestarts {
+		sup.fail(fmt.Errorf("%w: %w", ErrIntensity, err))
+		return
+	}
+	sup.restarts++
+
+	i := slices.Index(sup.children, c)
+	var restart []*child
+	switch sup.strategy {
+	case OneForAll:
+		restart = sup.children
+	case RestForOne:
+		restart = sup.children[i:]
+	default:
+		restart = []*child{c}
+	}
+	c.live = false
+	restart = slices.DeleteFunc(slices.Clone(restart), func(r *child) bool {
+		return r != c && !r.live
+	})
+	sup.stopChildren(restart)
+	for _, r := range restart {
+		sup.start(r)
+	}
+}
+
+// stopChildren cancels children, last started first.
+func (sup *Supervisor) stopChildren(children []*child) {
+	for _, c := range slices.Backward(children) {
+		if c.live {
+			c.live = false
+			c.task.Cancel()
+		}
+	}
+}
+
+// fail stops the supervisor's children and reports err to its OnExit
+// callbacks, propagating it if there are none.
+func (sup *Supervisor) fail(err error) {
+	sup.stopped, sup.err = true, err
+	sup.stopChildren(sup.children)
+	for _, fn := range sup.exits {
+		fn(err)
+	}
+	if err != nil && len(sup.exits) == 0 {
+		panic(err)
+	}
+}
+
+// Stop stops the supervisor and all of its children, last started
+// first.
+func (sup *Supervisor) Stop() {
+	if !sup.stopped {
+		sup.fail(nil)
+	}
+}
+
+// Stopped reports whether the supervisor has stopped, either because
+// it was stopped or because it escalated.
+func (sup *Supervisor) Stopped() bool {
+	return sup.stopped
+}
+
+// Err returns the error the supervisor escalated with, if any.
+func (sup *Supervisor) Err() error {
+	return sup.err
+}
+
+// Restarts returns the number of times the supervisor has applied its
+// strategy to restart children.
+func (sup *Supervisor) Restarts() int {
+	return sup.restarts
+}
+
+// Task returns the current task of the child with the given name, or
+// nil if there is none.
+func (sup *Supervisor) Task(name string) *Task {
+	for _, c := range sup.children {
+		if c.spec.Name == name {
+			return c.task
+		}
+	}
+	return nil
+}
+
+// OnExit registers fn to be called with the supervisor's error when it
+// stops. Registering a callback marks its escalations as observed.
+func (sup *Supervisor) OnExit(fn func(err error)) {
+	sup.exits = append(sup.exits, fn)
+}
@@ -0,0 +1,195 @@
+package sched
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/webriots/coro"
+)
+
+// worker returns a child that records its starts and stops, parks,
+// and panics when woken with fail set.
+func worker(name string, trace *[]string, fail *bool) Child {
+	return Child{Name: name, Run: func(t *Task) {
+		*trace = append(*trace, "start "+name)
+		defer func() { *trace = append(*trace, "stop "+name) }()
+		for {
+			t.Park()
+			if *fail {
+				*fail = false
+				panic(name + " failed")
+			}
+		}
+	}}
+}
+
+func supervise(strategy Strategy, opts ...SupervisorOption) (*Scheduler, *Supervisor, *[]string, *bool) {
+	s := New()
+	var trace []string
+	var fail bool
+	sup := NewSupervisor(s, strategy, []Child{
+		worker("a", &trace, &fail),
+		worker("b", &trace, &fail),
+		worker("c", &trace, &fail),
+	}, opts...)
+	s.Run()
+	trace = nil
+	return s, sup, &trace, &fail
+}
+
+func TestSupervisorStrategies(t *testing.T) {
+	for _, test := range []struct {
+		strategy Strategy
+		expected []string
+	}{
+		{OneForOne, []string{"stop b", "start b"}},
+		{OneForAll, []string{"stop b", "stop c", "stop a", "start a", "start b", "start c"}},
+		{RestForOne, []string{"stop b", "stop c", "start b", "start c"}},
+	} {
+		t.Run(test.strategy.String(), func(t *testing.T) {
+			s, sup, trace, fail := supervise(test.strategy)
+			*fail = true
+			sup.Task("b").Wake()
+			s.Run()
+
+			if !reflect.DeepEqual(*trace, test.expected) {
+				t.Errorf("Expected %v, got %v", test.expected, *trace)
+			}
+			if sup.Restarts() != 1 || sup.Stopped() || s.Live() != 3 {
+				t.Errorf("Expected one restart and 3 live children, got %d, %d", sup.Restarts(), s.Live())
+			}
+		})
+	}
+}
+
+func TestSupervisorIntensity(t *testing.T) {
+	clock := coro.NewManualClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	s, sup, trace, fail := supervise(OneForOne, WithIntensity(2, time.Minute), WithClock(clock))
+	var exitErr error
+	sup.OnExit(func(err error) { exitErr = err })
+
+	crash := func() {
+		*fail = true
+		sup.Task("a").Wake()
+		s.Run()
+	}
+
+	crash()
+	clock.Advance(40 * time.Second)
+	crash()
+	clock.Advance(40 * time.Second)
+	// The first failure has left the period, so this is the second
+	// in the last minute.
+	crash()
+	if sup.Stopped() || sup.Restarts() != 3 {
+		t.Fatalf("Expected the supervisor to keep restarting, got %d restarts", sup.Restarts())
+	}
+
+	*trace = nil
+	crash()
+	if !sup.Stopped() || s.Live() != 0 {
+		t.Fatal("Expected the supervisor to give up")
+	}
+	if !errors.Is(exitErr, ErrIntensity) || exitErr != sup.Err() {
+		t.Errorf("Expected ErrIntensity, got %v", exitErr)
+	}
+	if expected := "sched: supervisor restart intensity exceeded: a failed"; exitErr.Error() != expected {
+		t.Errorf("Expected %q, got %q", expected, exitErr.Error())
+	}
+	expected := []string{"stop a", "stop c", "stop b"}
+	if !reflect.DeepEqual(*trace, expected) {
+		t.Errorf("Expected %v, got %v", expected, *trace)
+	}
+}
+
+func TestSupervisorEscalationUnobserved(t *testing.T) {
+	s, sup, _, fail := supervise(OneForOne, WithIntensity(0, time.Minute))
+	*fail = true
+	sup.Task("a").Wake()
+
+	defer func() {
+		if err, ok := recover().(error); !ok || !errors.Is(err, ErrIntensity) {
+			t.Errorf("Expected ErrIntensity to propagate, got %v", err)
+		}
+	}()
+	s.Run()
+}
+
+func TestSupervisorNormalExit(t *testing.T) {
+	s := New()
+	var runs int
+	sup := NewSupervisor(s, OneForAll, []Child{
+		{Name: "once", Run: func(t *Task) { runs++ }},
+		{Name: "canceled", Run: func(t *Task) { t.Park() }},
+	})
+	s.Run()
+	sup.Task("canceled").Cancel()
+	s.Run()
+
+	if runs != 1 || sup.Restarts() != 0 || s.Live() != 0 {
+		t.Errorf("Expected finished children not to restart, got %d runs", runs)
+	}
+	if sup.Task("missing") != nil {
+		t.Error("Expected no task for an unknown child")
+	}
+}
+
+func TestSupervisorStop(t *testing.T) {
+	s, sup, trace, _ := supervise(OneForOne)
+	sup.Stop()
+	sup.Stop()
+
+	expected := []string{"stop c", "stop b", "stop a"}
+	if !reflect.DeepEqual(*trace, expected) {
+		t.Errorf("Expected %v, got %v", expected, *trace)
+	}
+	if !sup.Stopped() || sup.Err() != nil || s.Live() != 0 {
+		t.Error("Expected the supervisor to stop cleanly")
+	}
+}
+
+func TestSupervisorTree(t *testing.T) {
+	s := New()
+	var trace []string
+	var fail bool
+	var leafStarts int
+	root := NewSupervisor(s, OneForOne, []Child{
+		SupervisorChild("inner", OneForOne, []Child{
+			{Name: "leaf", Run: func(t *Task) {
+				leafStarts++
+				if leafStarts == 1 {
+					panic("leaf failed")
+				}
+				t.Park()
+			}},
+			worker("inner-worker", &trace, &fail),
+		}, WithIntensity(0, time.Minute)),
+		worker("sibling", &trace, &fail),
+	})
+	var rootErr error
+	root.OnExit(func(err error) { rootErr = err })
+	s.Run()
+
+	// The leaf's failure exceeds the inner supervisor's intensity, so
+	// the inner supervisor stops its children and fails, and the root
+	// restarts it, leaving the sibling alone. The first inner worker
+	// is stopped before it ever runs.
+	expected := []string{"start sibling", "start inner-worker"}
+	if !reflect.DeepEqual(trace, expected) {
+		t.Errorf("Expected %v, got %v", expected, trace)
+	}
+	if leafStarts != 2 || root.Restarts() != 1 || root.Stopped() {
+		t.Errorf("Expected the root to restart the inner supervisor once, got %d restarts", root.Restarts())
+	}
+	if s.Live() != 4 {
+		t.Errorf("Expected 4 live tasks, got %d", s.Live())
+	}
+
+	// Stopping the root stops the whole tree.
+	root.Stop()
+	if s.Live() != 0 || rootErr != nil {
+		t.Errorf("Expected the tree to stop cleanly, got %d live, %v", s.Live(), rootErr)
+	}
+}
@@ -0,0 +1,388 @@
+package sched
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/webriots/coro"
+)
+
+// waiter is a task queued on a synchronization primitive.
+type waiter struct {
+	t     *Task
+	n     int64
+	write bool
+	ready bool
+}
+
+// waitq is a FIFO queue of waiting tasks.
+type waitq struct {
+	waiters []*waiter
+}
+
+// front returns the first waiter, or nil if there is none.
+func (q *waitq) front() *waiter {
+	if len(q.waiters) == 0 {
+		return nil
+	}
+	return q.waiters[0]
+}
+
+// ready removes the first waiter from the queue and wakes it.
+func (q *waitq) ready() {
+	w := q.waiters[0]
+	q.waiters[0] = nil
+	q.waiters = q.waiters[1:]
+	w.ready = true
+	w.t.Wake()
+}
+
+// wait queues w and parks its task until w is readied. If the task is
+// canceled while it waits, w is removed from the queue and grant is
+// called to pass on what w was waiting for, or, if w had already been
+// readied, release is called to give back what it was granted.
+// Either function may be nil. A task whose cancellation was deferred
+// by Cond.Wait stops waiting without w being readied.
+func (q *waitq) wait(on any, w *waiter, release, grant func()) {
+	q.waiters = append(q.waiters, w)
+	w.t.block(on)
+	finished := false
+	defer func() {
+		w.t.unblock()
+		switch {
+		case finished:
+		case w.ready:
+			if release != nil {
+				release()
+			}
+		default:
+			if i := slices.Index(q.waiters, w); i >= 0 {
+				q.waiters = slices.Delete(q.waiters, i, i+1)
+			}
+			if grant != nil {
+				grant()
+			}
+		}
+	}()
+	for !w.ready && !(w.t.interruptible && w.t.canceling) {
+		w.t.Park()
+	}
+	finished = w.ready
+}
+
+// holder is implemented by the primitives that know which task holds
+// them, for the wait-for graph of a DeadlockError.
+type holder interface {
+	holder() *Task
+}
+
+// Locker is a lock that suspends the task waiting to acquire it.
+type Locker interface {
+	Lock(t *Task)
+	Unlock()
+}
+
+// Mutex is a mutual exclusion lock for tasks. Lock parks the calling
+// task instead of blocking its goroutine, which would stop every task
+// of the scheduler. Tasks acquire the lock in the order they asked for
+// it. The zero Mutex is unlocked.
+//
+// As with sync.Mutex, a locked Mutex is not associated with a task: it
+// may be locked by one task and unlocked by another.
+type Mutex struct {
+	locked bool
+	owner  *Task
+	q      waitq
+}
+
+// Lock locks m, parking t until m is available. If t is canceled
+// while it waits, it does not acquire m.
+func (m *Mutex) Lock(t *Task) {
+	if m.TryLock() {
+		m.owner = t
+		return
+	}
+	m.q.wait(m, &waiter{t: t}, m.Unlock, nil)
+}
+
+// TryLock locks m if it is unlocked, and reports whether it did. Since
+// Unlock hands m directly to the first waiting task, m is never
+// unlocked while a task waits for it.
+func (m *Mutex) TryLock() bool {
+	if m.locked {
+		return false
+	}
+	m.locked = true
+	return true
+}
+
+// Unlock unlocks m, handing it to the first waiting task. It panics if
+// m is not locked.
+func (m *Mutex) Unlock() {
+	if !m.locked {
+		panic("sched: unlock of unlocked mutex")
+	}
+	m.owner = nil
+	if w := m.q.front(); w != nil {
+		m.owner = w.t
+		m.q.ready()
+		return
+	}
+	m.locked = false
+}
+
+// holder returns the task that locked m with Lock, if it still holds
+// it.
+func (m *Mutex) holder() *Task {
+	return m.owner
+}
+
+// RWMutex is a reader/writer mutual exclusion lock for tasks. The lock
+// can be held by any number of readers or a single writer. Tasks
+// acquire it in the order they asked for it, so a reader waits behind
+// a waiting writer even while other readers hold the lock. The zero
+// RWMutex is unlocked.
+type RWMutex struct {
+	readers int
+	writer  bool
+	owner   *Task
+	q       waitq
+}
+
+// Lock locks rw for writing, parking t until it is available.
+func (rw *RWMutex) Lock(t *Task) {
+	if rw.TryLock() {
+		rw.owner = t
+		return
+	}
+	rw.q.wait(rw, &waiter{t: t, write: true}, rw.Unlock, rw.grant)
+}
+
+// TryLock locks rw for writing if it is free and no task is waiting
+// for it, and reports whether it did.
+func (rw *RWMutex) TryLock() bool {
+	if rw.writer || rw.readers > 0 || rw.q.front() != nil {
+		return false
+	}
+	rw.writer = true
+	return true
+}
+
+// Unlock unlocks rw for writing. It panics if rw is not locked for
+// writing.
+func (rw *RWMutex) Unlock() {
+	if !rw.writer {
+		panic("sched: unlock of unlocked rwmutex")
+	}
+	rw.writer, rw.owner = false, nil
+	rw.grant()
+}
+
+// holder returns the task that locked rw for writing with Lock, if it
+// still holds it.
+func (rw *RWMutex) holder() *Task {
+	return rw.owner
+}
+
+// RLock locks rw for reading, parking t until it is available.
+func (rw *RWMutex) RLock(t *Task) {
+	if rw.TryRLock() {
+		return
+	}
+	rw.q.wait(rw, &waiter{t: t}, rw.RUnlock, rw.grant)
+}
+
+// TryRLock locks rw for reading if no writer holds it or is waiting
+// for it, and reports whether it did.
+func (rw *RWMutex) TryRLock() bool {
+	if rw.writer || rw.q.front() != nil {
+		return false
+	}
+	rw.readers++
+	return true
+}
+
+// RUnlock undoes a single RLock call. It panics if rw is not locked
+// for reading.
+func (rw *RWMutex) RUnlock() {
+	if rw.readers == 0 {
+		panic("sched: runlock of unlocked rwmutex")
+	}
+	rw.readers--
+	rw.grant()
+}
+
+// RLocker returns a Locker that locks rw for reading.
+func (rw *RWMutex) RLocker() Locker {
+	return (*rlocker)(rw)
+}
+
+// grant hands rw to the waiting tasks at the front of the queue that
+// can hold it together.
+func (rw *RWMutex) grant() {
+	for w := rw.q.front(); w != nil && !rw.writer; w = rw.q.front() {
+		if w.write {
+			if rw.readers > 0 {
+				return
+			}
+			rw.writer, rw.owner = true, w.t
+		} else {
+			rw.readers++
+		}
+		rw.q.ready()
+	}
+}
+
+type rlocker RWMutex
+
+func (r *rlocker) Lock(t *Task) { (*RWMutex)(r).RLock(t) }
+func (r *rlocker) Unlock()      { (*RWMutex)(r).RUnlock() }
+
+// Semaphore is a weighted semaphore for tasks. Tasks acquire it in
+// the order they asked for it, so a large request is not starved by a
+// stream of smaller ones.
+type Semaphore struct {
+	size, cur int64
+	q         waitq
+}
+
+// NewSemaphore returns a semaphore with a total weight of n.
+func NewSemaphore(n int64) *Semaphore {
+	return &Semaphore{size: n}
+}
+
+// Acquire acquires a weight of n, parking t until it is available.
+// It panics if n is negative, or exceeds the semaphore's size, since
+// the request could never be satisfied.
+func (s *Semaphore) Acquire(t *Task, n int64) {
+	checkWeight(n)
+	if n > s.size {
+		panic("sched: semaphore acquire exceeds size")
+	}
+	if s.TryAcquire(n) {
+		return
+	}
+	s.q.wait(s, &waiter{t: t, n: n}, func() { s.Release(n) }, s.grant)
+}
+
+// TryAcquire acquires a weight of n if it is available and no task is
+// waiting, and reports whether it did. It panics if n is negative.
+func (s *Semaphore) TryAcquire(n int64) bool {
+	checkWeight(n)
+	if s.size-s.cur < n || s.q.front() != nil {
+		return false
+	}
+	s.cur += n
+	return true
+}
+
+// Release releases a weight of n, handing it to the waiting tasks. It
+// panics if n is negative or more is released than is held.
+func (s *Semaphore) Release(n int64) {
+	checkWeight(n)
+	if n > s.cur {
+		panic("sched: semaphore released more than held")
+	}
+	s.cur -= n
+	s.grant()
+}
+
+// checkWeight panics if n is negative, which would otherwise change
+// the capacity of a semaphore.
+func checkWeight(n int64) {
+	if n < 0 {
+		panic("sched: negative semaphore weight")
+	}
+}
+
+// grant hands the available weight to the waiting tasks at the front
+// of the queue whose requests it satisfies.
+func (s *Semaphore) grant() {
+	for w := s.q.front(); w != nil && s.size-s.cur >= w.n; w = s.q.front() {
+		s.cur += w.n
+		s.q.ready()
+	}
+}
+
+// Cond is a condition variable for tasks: a rendezvous point for tasks
+// waiting for or announcing a change of the state guarded by L.
+// Waiting tasks are signaled in the order they began to wait.
+type Cond struct {
+	L Locker
+	q waitq
+}
+
+// NewCond returns a condition variable for l.
+func NewCond(l Locker) *Cond {
+	return &Cond{L: l}
+}
+
+// Wait unlocks c.L, parks t until it is signaled, and locks c.L again
+// before returning. As with sync.Cond, the condition should be checked
+// in a loop around Wait.
+//
+// Callers expect to hold c.L while they unwind, typically to unlock it
+// in a deferred call, but relocking it may mean waiting for another
+// task. So if t is canceled while it waits, it is not unwound at once:
+// it stops waiting, relocks c.L the next time the scheduler runs it,
+// and only then unwinds with an error wrapping coro.ErrCanceled.
+func (c *Cond) Wait(t *Task) {
+	w := &waiter{t: t}
+	c.L.Unlock()
+	t.interruptible = true
+	c.q.wait(c, w, nil, nil)
+	t.interruptible = false
+	c.L.Lock(t)
+	if t.canceling {
+		panic(fmt.Errorf("%w", coro.ErrCanceled))
+	}
+}
+
+// Signal wakes the task that has waited longest, if there is one.
+func (c *Cond) Signal() {
+	if c.q.front() != nil {
+		c.q.ready()
+	}
+}
+
+// Broadcast wakes every waiting task.
+func (c *Cond) Broadcast() {
+	for c.q.front() != nil {
+		c.q.ready()
+	}
+}
+
+// WaitGroup waits for a collection of tasks or other operations to
+// finish. The zero WaitGroup has a count of zero.
+type WaitGroup struct {
+	n int
+	q waitq
+}
+
+// Add adds delta, which may be negative, to the count. When the count
+// reaches zero, every task waiting in Wait is woken. Add panics if the
+// count becomes negative.
+func (wg *WaitGroup) Add(delta int) {
+	wg.n += delta
+	if wg.n < 0 {
+		panic("sched: negative WaitGroup counter")
+	}
+	if wg.n == 0 {
+		for wg.q.front() != nil {
+			wg.q.ready()
+		}
+	}
+}
+
+// Done decrements the count by one.
+func (wg *WaitGroup) Done() {
+	wg.Add(-1)
+}
+
+// Wait parks t until the count is zero.
+func (wg *WaitGroup) Wait(t *Task) {
+	if wg.n == 0 {
+		return
+	}
+	wg.q.wait(wg, &waiter{t: t}, nil, nil)
+}
@@ -0,0 +1,423 @@
+package sched
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/webriots/coro"
+)
+
+func TestMutex(t *testing.T) {
+	s := New()
+	var m Mutex
+	var trace []string
+	for _, name := range []string{"a", "b", "c"} {
+		s.Spawn(func(t *Task) {
+			m.Lock(t)
+			trace = append(trace, name+" locked")
+			t.Yield()
+			trace = append(trace, name+" unlocked")
+			m.Unlock()
+		})
+	}
+	s.Run()
+
+	expected := []string{
+		"a locked", "a unlocked",
+		"b locked", "b unlocked",
+		"c locked", "c unlocked",
+	}
+	if !reflect.DeepEqual(trace, expected) {
+		t.Errorf("Expected %v, got %v", expected, trace)
+	}
+	if !m.TryLock() {
+		t.Error("Expected the mutex to be free")
+	}
+}
+
+func TestMutexFairness(t *testing.T) {
+	s := New()
+	var m Mutex
+	var order []int
+	s.Spawn(func(t *Task) {
+		m.Lock(t)
+		t.Yield()
+		t.Yield()
+		m.Unlock()
+		// The waiters queued first get the lock even though this task
+		// asks for it again before they have run.
+		m.Lock(t)
+		order = append(order, 0)
+		m.Unlock()
+	})
+	for i := 1; i <= 2; i++ {
+		s.Spawn(func(t *Task) {
+			m.Lock(t)
+			order = append(order, i)
+			m.Unlock()
+		})
+	}
+	s.Run()
+
+	if expected := []int{1, 2, 0}; !reflect.DeepEqual(order, expected) {
+		t.Errorf("Expected %v, got %v", expected, order)
+	}
+}
+
+func TestMutexUnlockUnlocked(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected panic but got none")
+		}
+	}()
+	var m Mutex
+	m.Unlock()
+}
+
+func TestMutexCancelWaiter(t *testing.T) {
+	s := New()
+	var m Mutex
+	var locked []string
+	holder := s.Spawn(func(t *Task) {
+		m.Lock(t)
+		t.Park()
+		m.Unlock()
+	})
+	waiter := s.Spawn(func(t *Task) {
+		m.Lock(t)
+		locked = append(locked, "waiter")
+		m.Unlock()
+	})
+	s.Spawn(func(t *Task) {
+		m.Lock(t)
+		locked = append(locked, "last")
+		m.Unlock()
+	})
+	s.Run()
+
+	waiter.Cancel()
+	holder.Wake()
+	s.Run()
+	if !reflect.DeepEqual(locked, []string{"last"}) {
+		t.Errorf("Expected only the last task to lock, got %v", locked)
+	}
+
+	// A waiter canceled after being handed the lock passes it on.
+	holder = s.Spawn(func(t *Task) {
+		m.Lock(t)
+		t.Yield()
+		m.Unlock()
+	})
+	waiter = s.Spawn(func(t *Task) { m.Lock(t) })
+	s.Step()
+	s.Step()
+	s.Step()
+	waiter.Cancel()
+	if !holder.Done() || !m.TryLock() {
+		t.Error("Expected the canceled waiter to release the lock")
+	}
+}
+
+func TestRWMutex(t *testing.T) {
+	s := New()
+	var rw RWMutex
+	var trace []string
+	reader := func(name string) func(*Task) {
+		return func(t *Task) {
+			rw.RLock(t)
+			trace = append(trace, name+" reading")
+			t.Yield()
+			trace = append(trace, name+" done")
+			rw.RUnlock()
+		}
+	}
+	s.Spawn(reader("r1"))
+	s.Spawn(reader("r2"))
+	s.Spawn(func(t *Task) {
+		rw.Lock(t)
+		trace = append(trace, "w writing")
+		t.Yield()
+		trace = append(trace, "w done")
+		rw.Unlock()
+	})
+	// r3 queues behind the waiting writer even though r1 and r2 hold
+	// the lock for reading.
+	s.Spawn(reader("r3"))
+	s.Run()
+
+	expected := []string{
+		"r1 reading", "r2 reading", "r1 done", "r2 done",
+		"w writing", "w done",
+		"r3 reading", "r3 done",
+	}
+	if !reflect.DeepEqual(trace, expected) {
+		t.Errorf("Expected %v, got %v", expected, trace)
+	}
+	if !rw.TryLock() || rw.TryRLock() {
+		t.Error("Expected the lock to be free and then held for writing")
+	}
+}
+
+func TestSemaphore(t *testing.T) {
+	s := New()
+	sem := NewSemaphore(3)
+	var trace []string
+	acquire := func(name string, n int64) {
+		s.Spawn(func(t *Task) {
+			sem.Acquire(t, n)
+			trace = append(trace, name)
+			t.Yield()
+			sem.Release(n)
+		})
+	}
+	acquire("a2", 2)
+	acquire("b3", 3)
+	// c1 fits alongside a2, but waits behind b3.
+	acquire("c1", 1)
+	s.Run()
+
+	if expected := []string{"a2", "b3", "c1"}; !reflect.DeepEqual(trace, expected) {
+		t.Errorf("Expected %v, got %v", expected, trace)
+	}
+	if !sem.TryAcquire(3) {
+		t.Error("Expected the full weight to be available")
+	}
+}
+
+func TestSemaphoreOversized(t *testing.T) {
+	s := New()
+	sem := NewSemaphore(1)
+	task := s.Spawn(func(t *Task) { sem.Acquire(t, 2) })
+	task.OnExit(func(error) {})
+	s.Run()
+	if task.Err() == nil {
+		t.Error("Expected acquiring more than the size to panic")
+	}
+}
+
+func TestSemaphoreNegative(t *testing.T) {
+	sem := NewSemaphore(1)
+	for name, fn := range map[string]func(){
+		"TryAcquire": func() { sem.TryAcquire(-1) },
+		"Release":    func() { sem.Release(-1) },
+		"Acquire":    func() { sem.Acquire(nil, -1) },
+	} {
+		func() {
+			defer func() {
+				if p := recover(); p != "sched: negative semaphore weight" {
+					t.Errorf("%s: expected a negative weight panic, got %v", name, p)
+				}
+			}()
+			fn()
+		}()
+	}
+	if sem.TryAcquire(2) {
+		t.Error("Expected the semaphore's size to be unchanged")
+	}
+}
+
+func TestCond(t *testing.T) {
+	s := New()
+	var m Mutex
+	c := NewCond(&m)
+	var queue []int
+	var got []int
+	for range 2 {
+		s.Spawn(func(t *Task) {
+			m.Lock(t)
+			defer m.Unlock()
+			for len(queue) == 0 {
+				c.Wait(t)
+			}
+			got = append(got, queue[0])
+			queue = queue[1:]
+		})
+	}
+	s.Spawn(func(t *Task) {
+		m.Lock(t)
+		queue = append(queue, 1)
+		c.Signal()
+		m.Unlock()
+		t.Yield()
+		m.Lock(t)
+		queue = append(queue, 2)
+		c.Broadcast()
+		m.Unlock()
+	})
+	s.Run()
+
+	if expected := []int{1, 2}; !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+}
+
+func TestCondCancel(t *testing.T) {
+	for _, holder := range []bool{false, true} {
+		s := New()
+		var m Mutex
+		c := NewCond(&m)
+		var ready, unlocked bool
+		waiter := s.Spawn(func(t *Task) {
+			m.Lock(t)
+			defer func() {
+				unlocked = true
+				m.Unlock()
+			}()
+			for !ready {
+				c.Wait(t)
+			}
+		})
+		// A parked task could still signal the waiter, so waiting
+		// alone is not a deadlock.
+		idle := s.Spawn(func(t *Task) { t.Park() })
+		s.Run()
+
+		if holder {
+			// The task canceling the waiter holds the lock, so the
+			// waiter must wait for it before unwinding.
+			s.Spawn(func(t *Task) {
+				m.Lock(t)
+				waiter.Cancel()
+				t.Yield()
+				m.Unlock()
+			})
+		} else {
+			waiter.Cancel()
+		}
+		s.Run()
+
+		if !waiter.Done() || !errors.Is(waiter.Err(), coro.ErrCanceled) {
+			t.Errorf("holder %v: Expected the waiter to be canceled, got %v", holder, waiter.Err())
+		}
+		if !unlocked || !m.TryLock() {
+			t.Errorf("holder %v: Expected the waiter to unlock the relocked mutex", holder)
+		}
+		idle.Cancel()
+	}
+}
+
+func TestWaitGroup(t *testing.T) {
+	s := New()
+	var wg WaitGroup
+	var finished int
+	var waited bool
+	wg.Add(3)
+	s.Spawn(func(t *Task) {
+		wg.Wait(t)
+		waited = finished == 3
+	})
+	for range 3 {
+		s.Spawn(func(t *Task) {
+			defer wg.Done()
+			t.Yield()
+			finished++
+		})
+	}
+	s.Run()
+
+	if !waited {
+		t.Error("Expected Wait to return after every task finished")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected panic but got none")
+		}
+	}()
+	wg.Done()
+}
+
+func TestDeadlock(t *testing.T) {
+	s := New()
+	var a, b Mutex
+	lock := func(first, second *Mutex) func(*Task) {
+		return func(t *Task) {
+			first.Lock(t)
+			defer first.Unlock()
+			t.Yield()
+			second.Lock(t)
+			second.Unlock()
+		}
+	}
+	t1 := s.Spawn(lock(&a, &b))
+	t2 := s.Spawn(lock(&b, &a))
+
+	func() {
+		defer func() {
+			err, _ := recover().(error)
+			var deadlock *DeadlockError
+			if !errors.As(err, &deadlock) || !errors.Is(err, ErrDeadlock) {
+				t.Fatalf("Expected a deadlock, got %v", err)
+			}
+			if !reflect.DeepEqual(deadlock.Tasks, []*Task{t1, t2}) {
+				t.Errorf("Expected both tasks to be blocked, got %v", deadlock.Tasks)
+			}
+		}()
+		s.Run()
+	}()
+
+	t1.Cancel()
+	s.Run()
+	if !t2.Done() || s.Live() != 0 {
+		t.Error("Expected canceling one task to break the deadlock")
+	}
+}
+
+func TestDeadlockParked(t *testing.T) {
+	s := New()
+	var m Mutex
+	s.Spawn(func(t *Task) {
+		m.Lock(t)
+		t.Park()
+	})
+	s.Spawn(func(t *Task) { m.Lock(t) })
+	// The parked task may be woken from outside, so this is not a
+	// deadlock.
+	s.Run()
+	if s.Live() != 2 {
+		t.Errorf("Expected 2 live tasks, got %d", s.Live())
+	}
+}
+
+func TestDeadlockGraph(t *testing.T) {
+	s := New()
+	var m Mutex
+	var wg WaitGroup
+	wg.Add(1)
+	holder := s.Spawn(func(t *Task) {
+		m.Lock(t)
+		wg.Wait(t)
+	})
+	waiter := s.Spawn(func(t *Task) { m.Lock(t) })
+
+	var err error
+	func() {
+		defer func() { err, _ = recover().(error) }()
+		s.Run()
+	}()
+	var deadlock *DeadlockError
+	if !errors.As(err, &deadlock) {
+		t.Fatalf("Expected a deadlock, got %v", err)
+	}
+
+	expected := []Wait{
+		{Task: holder, On: &wg},
+		{Task: waiter, On: &m, Holder: holder},
+	}
+	if !reflect.DeepEqual(deadlock.Waits, expected) {
+		t.Errorf("Expected %v, got %v", expected, deadlock.Waits)
+	}
+	msg := err.Error()
+	for _, want := range []string{
+		"task 1 waits on *sched.WaitGroup",
+		"task 2 waits on *sched.Mutex",
+		"held by task 1",
+		"created at:\ngithub.com/webriots/coro/sched.TestDeadlockGraph",
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("Expected error to contain %q, got:\n%s", want, msg)
+		}
+	}
+}
@@ -0,0 +1,273 @@
+package sim
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// errPruned aborts an execution of Check whose remaining schedules are
+// all equivalent to ones already explored.
+var errPruned = errors.New("sim: schedule pruned")
+
+// Violation is a schedule under which a test checked by Check failed.
+// Schedule lists the spawn indexes of the processes in the order they
+// ran, and Names their names.
+type Violation struct {
+	Schedule []int
+	Names    []string
+	Err      error
+}
+
+func (v *Violation) Error() string {
+	return fmt.Sprintf("sim: schedule %s: %v", strings.Join(v.Names, ","), v.Err)
+}
+
+func (v *Violation) Unwrap() error {
+	return v.Err
+}
+
+// CheckStats reports the work done by Check.
+type CheckStats struct {
+	// Schedules is the number of schedules run to completion.
+	Schedules int
+	// Pruned is the number of executions abandoned by partial-order
+	// reduction.
+	Pruned int
+	// Truncated is true if the schedule limit stopped the search.
+	Truncated bool
+}
+
+// CheckOption configures Check.
+type CheckOption func(*checker)
+
+// WithMaxDepth bounds the search: choices after the first depth steps
+// of a schedule are not explored, and the lowest-numbered runnable
+// process runs instead. The default is 1000.
+func WithMaxDepth(depth int) CheckOption {
+	return func(c *checker) {
+		c.maxDepth = depth
+	}
+}
+
+// WithMaxSchedules stops the search after n schedules. The default, 0,
+// is no limit.
+func WithMaxSchedules(n int) CheckOption {
+	return func(c *checker) {
+		c.maxSchedules = n
+	}
+}
+
+// WithReduction enables partial-order reduction with sleep sets: once
+// the schedules starting with a step have been explored, the search
+// avoids reordering that step with later steps that are independent
+// of it, as those schedules reach the same states. Two steps are
+// independent if they touch no common object, so every access to
+// state shared between processes must be declared with Touch or made
+// through a Mailbox; undeclared accesses can make the search miss
+// schedules.
+func WithReduction() CheckOption {
+	return func(c *checker) {
+		c.reduce = true
+	}
+}
+
+// WithSimOptions passes opts to each simulation run by Check.
+func WithSimOptions(opts ...Option) CheckOption {
+	return func(c *checker) {
+		c.opts = append(c.opts, opts...)
+	}
+}
+
+// choice is a point in a schedule at which more than one process
+// could run.
+type choice struct {
+	enabled []int
+	chosen  int
+	done    []int
+	sleep   map[int]footprint
+	steps   map[int]footprint
+}
+
+// checker is the state of a depth-first search over schedules.
+type checker struct {
+	maxDepth     int
+	maxSchedules int
+	reduce       bool
+	opts         []Option
+	stack        []*choice
+	stats        CheckStats
+}
+
+// Check runs test under every schedule of its processes, depth first,
+// and returns the first *Violation, a schedule under which test
+// returned an error. Unlike Explore, which samples schedules at
+// random, Check enumerates the orders in which runnable processes can
+// take their next step, so it finds ordering bugs that random testing
+// misses; the number of schedules grows quickly, so it suits small
+// tests. The test must be deterministic, and is run once per schedule
+// on a new simulation, as for Run.
+func Check(test func(s *Sim) error, opts ...CheckOption) (CheckStats, error) {
+	c := &checker{maxDepth: 1000}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(c)
+		}
+	}
+	for {
+		if c.maxSchedules > 0 && c.stats.Schedules >= c.maxSchedules {
+			c.stats.Truncated = true
+			return c.stats, nil
+		}
+		if v := c.execute(test); v != nil {
+			return c.stats, v
+		}
+		if !c.backtrack() {
+			return c.stats, nil
+		}
+	}
+}
+
+// RunSchedule runs test once, choosing the processes to run in the
+// order given by schedule, such as that of a Violation, and then the
+// lowest-numbered runnable process once the schedule is exhausted.
+func RunSchedule(schedule []int, test func(s *Sim) error, opts ...Option) error {
+	s := New(0, opts...)
+	defer s.Close()
+	depth := 0
+	s.choose = func(runnable []*Proc) int {
+		i := lowest(runnable)
+		if depth < len(schedule) {
+			i = slices.IndexFunc(runnable, func(p *Proc) bool { return p.id == schedule[depth] })
+			if i < 0 {
+				panic(fmt.Sprintf("sim: process %d is not runnable at step %d", schedule[depth], depth))
+			}
+		}
+		depth++
+		return i
+	}
+	return test(s)
+}
+
+// execute runs test once, following the choices on the stack and
+// making new ones as needed, and returns a violation if it fails.
+func (c *checker) execute(test func(s *Sim) error) (v *Violation) {
+	s := New(0, c.opts...)
+	defer s.Close()
+	var schedule []int
+	var names []string
+	depth := 0
+	s.choose = func(runnable []*Proc) int {
+		i := c.choose(depth, runnable)
+		schedule = append(schedule, runnable[i].id)
+		names = append(names, runnable[i].name)
+		depth++
+		return i
+	}
+	s.observe = func(p *Proc, fp footprint) {
+		if d := depth - 1; d < len(c.stack) {
+			c.stack[d].steps[p.id] = fp
+		}
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			if p != errPruned {
+				panic(p)
+			}
+			c.stats.Pruned++
+			v = nil
+		}
+	}()
+	err := test(s)
+	c.stats.Schedules++
+	if err != nil {
+		return &Violation{Schedule: schedule, Names: names, Err: err}
+	}
+	return nil
+}
+
+// choose returns the index in runnable of the process to run at the
+// given depth of the schedule.
+func (c *checker) choose(depth int, runnable []*Proc) int {
+	if depth >= c.maxDepth {
+		return lowest(runnable)
+	}
+	if depth == len(c.stack) {
+		c.stack = append(c.stack, c.newChoice(depth, runnable))
+	}
+	ch := c.stack[depth]
+	if ch.chosen < 0 {
+		panic(errPruned)
+	}
+	return slices.IndexFunc(runnable, func(p *Proc) bool { return p.id == ch.chosen })
+}
+
+// newChoice returns the choice point reached at depth, whose sleep set
+// holds the processes whose next steps were already explored earlier
+// in the search and are independent of the step that led here.
+func (c *checker) newChoice(depth int, runnable []*Proc) *choice {
+	ch := &choice{sleep: make(map[int]footprint), steps: make(map[int]footprint)}
+	for _, p := range runnable {
+		ch.enabled = append(ch.enabled, p.id)
+	}
+	slices.Sort(ch.enabled)
+
+	if c.reduce && depth > 0 {
+		parent := c.stack[depth-1]
+		last := parent.steps[parent.chosen]
+		for id, fp := range parent.sleep {
+			if fp.independent(last) {
+				ch.sleep[id] = fp
+			}
+		}
+		for _, id := range parent.done {
+			if fp := parent.steps[id]; fp.independent(last) {
+				ch.sleep[id] = fp
+			}
+		}
+	}
+	ch.chosen = ch.next()
+	return ch
+}
+
+// next returns the first enabled process that has neither been
+// explored nor is asleep, or -1.
+func (ch *choice) next() int {
+	for _, id := range ch.enabled {
+		if _, asleep := ch.sleep[id]; !asleep && !slices.Contains(ch.done, id) {
+			return id
+		}
+	}
+	return -1
+}
+
+// backtrack advances the deepest choice point that has unexplored
+// alternatives, discarding those below it, and reports whether there
+// was one.
+func (c *checker) backtrack() bool {
+	for len(c.stack) > 0 {
+		ch := c.stack[len(c.stack)-1]
+		if ch.chosen >= 0 {
+			ch.done = append(ch.done, ch.chosen)
+		}
+		if ch.chosen = ch.next(); ch.chosen >= 0 {
+			return true
+		}
+		c.stack = c.stack[:len(c.stack)-1]
+	}
+	return false
+}
+
+// lowest returns the index of the lowest-numbered process in
+// runnable.
+func lowest(runnable []*Proc) int {
+	i := 0
+	for j, p := range runnable {
+		if p.id < runnable[i].id {
+			i = j
+		}
+	}
+	return i
+}
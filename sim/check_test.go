@@ -0,0 +1,182 @@
+package sim
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// counter runs two processes that each increment a shared counter
+// twice. If atomic is false, each increment reads, yields and then
+// writes, which loses updates under some schedules.
+func counter(atomic bool) func(s *Sim) error {
+	return func(s *Sim) error {
+		count := 0
+		for _, name := range []string{"a", "b"} {
+			s.Spawn(name, func(p *Proc) {
+				for range 2 {
+					s.Touch(&count)
+					v := count
+					if !atomic {
+						p.Yield()
+						s.Touch(&count)
+					}
+					count = v + 1
+					p.Yield()
+				}
+			})
+		}
+		if err := s.Run(); err != nil {
+			return err
+		}
+		if count != 4 {
+			return fmt.Errorf("count is %d", count)
+		}
+		return nil
+	}
+}
+
+// independent runs n processes that each take steps steps touching
+// only their own state.
+func independent(n, steps int) func(s *Sim) error {
+	return func(s *Sim) error {
+		for i := range n {
+			s.Spawn(fmt.Sprint(i), func(p *Proc) {
+				key := fmt.Sprint("local", i)
+				for range steps {
+					s.Touch(key)
+					p.Yield()
+				}
+			})
+		}
+		return s.Run()
+	}
+}
+
+func TestCheckViolation(t *testing.T) {
+	for _, reduce := range []bool{false, true} {
+		opts := []CheckOption{}
+		if reduce {
+			opts = append(opts, WithReduction())
+		}
+		_, err := Check(counter(false), opts...)
+		var v *Violation
+		if !errors.As(err, &v) {
+			t.Fatalf("Expected a violation (reduction %v), got %v", reduce, err)
+		}
+		if v.Err.Error() != "count is 3" && v.Err.Error() != "count is 2" {
+			t.Errorf("Expected a lost update, got %v", v.Err)
+		}
+		if len(v.Names) != len(v.Schedule) || v.Names[0] != "a" {
+			t.Errorf("Expected named schedule, got %v", v.Names)
+		}
+
+		if err := RunSchedule(v.Schedule, counter(false)); err == nil || err.Error() != v.Err.Error() {
+			t.Errorf("Expected the schedule to reproduce %v, got %v", v.Err, err)
+		}
+	}
+}
+
+func TestCheckPass(t *testing.T) {
+	stats, err := Check(counter(true))
+	if err != nil {
+		t.Fatalf("Expected no violation, got %v", err)
+	}
+	// Each process takes three steps: two increments and its return.
+	if stats.Schedules != 20 || stats.Truncated {
+		t.Errorf("Expected all 20 interleavings, got %+v", stats)
+	}
+}
+
+func TestCheckReduction(t *testing.T) {
+	full, err := Check(independent(3, 2))
+	if err != nil {
+		t.Fatalf("Expected no violation, got %v", err)
+	}
+	// Three processes of three steps each: 9!/(3!3!3!) interleavings.
+	if full.Schedules != 1680 {
+		t.Errorf("Expected 1680 schedules, got %d", full.Schedules)
+	}
+
+	reduced, err := Check(independent(3, 2), WithReduction())
+	if err != nil {
+		t.Fatalf("Expected no violation, got %v", err)
+	}
+	if reduced.Schedules != 1 {
+		t.Errorf("Expected independent processes to need one schedule, got %+v", reduced)
+	}
+
+	dependent, _ := Check(counter(true), WithReduction())
+	if dependent.Schedules < 2 || dependent.Schedules > 20 {
+		t.Errorf("Expected dependent steps to be reordered, got %+v", dependent)
+	}
+}
+
+func TestCheckMailbox(t *testing.T) {
+	// The reply can overtake the request on its way to the logger,
+	// which is caught even though mailboxes declare no Touch.
+	test := func(s *Sim) error {
+		log := NewMailbox[string](s)
+		var first string
+		s.Spawn("client", func(p *Proc) { log.Send("request") })
+		s.Spawn("server", func(p *Proc) { log.Send("reply") })
+		s.Spawn("logger", func(p *Proc) {
+			first = log.Recv(p)
+			log.Recv(p)
+		})
+		if err := s.Run(); err != nil {
+			return err
+		}
+		if first != "request" {
+			return errors.New("reply logged first")
+		}
+		return nil
+	}
+	if _, err := Check(test, WithReduction()); err == nil || err.Error() != "sim: schedule server,client,logger: reply logged first" {
+		t.Errorf("Expected the reply to overtake the request, got %v", err)
+	}
+}
+
+func TestCheckTimers(t *testing.T) {
+	// Messages sent with the same latency arrive in the order they were
+	// sent, so reduction must not treat the sends as independent.
+	test := func(s *Sim) error {
+		inbox := NewMailbox[string](s)
+		var first string
+		s.Spawn("a", func(p *Proc) { inbox.SendAfter("a", time.Second) })
+		s.Spawn("b", func(p *Proc) { inbox.SendAfter("b", time.Second) })
+		s.Spawn("r", func(p *Proc) {
+			first = inbox.Recv(p)
+			inbox.Recv(p)
+		})
+		if err := s.Run(); err != nil {
+			return err
+		}
+		if first != "a" {
+			return fmt.Errorf("%s arrived first", first)
+		}
+		return nil
+	}
+	for _, reduce := range []bool{false, true} {
+		opts := []CheckOption{}
+		if reduce {
+			opts = append(opts, WithReduction())
+		}
+		if _, err := Check(test, opts...); err == nil || err.Error() != "sim: schedule b,a,r,r: b arrived first" {
+			t.Errorf("Expected b to arrive first (reduction %v), got %v", reduce, err)
+		}
+	}
+}
+
+func TestCheckLimits(t *testing.T) {
+	stats, err := Check(independent(3, 2), WithMaxSchedules(10))
+	if err != nil || stats.Schedules != 10 || !stats.Truncated {
+		t.Errorf("Expected the search to stop after 10 schedules, got %+v, %v", stats, err)
+	}
+
+	stats, err = Check(independent(3, 2), WithMaxDepth(2))
+	if err != nil || stats.Schedules != 9 {
+		t.Errorf("Expected 3*3 schedules within depth 2, got %+v, %v", stats, err)
+	}
+}
@@ -0,0 +1,44 @@
+package sim
+
+import "fmt"
+
+// Failure is a simulation run that failed, with the seed that
+// reproduces it.
+type Failure struct {
+	Seed uint64
+	Err  error
+}
+
+func (f *Failure) Error() string {
+	return fmt.Sprintf("sim: seed %d: %v", f.Seed, f.Err)
+}
+
+func (f *Failure) Unwrap() error {
+	return f.Err
+}
+
+// Run runs test on a new simulation with the given seed and options.
+// The test builds the system by spawning processes, runs the
+// simulation, and checks its invariants, returning an error if they
+// do not hold. Processes still live when the test returns are
+// killed. Since a simulation is a function of its seed, Run
+// replays the failures reported by Explore exactly.
+func Run(seed uint64, test func(s *Sim) error, opts ...Option) error {
+	s := New(seed, opts...)
+	defer s.Close()
+	if err := test(s); err != nil {
+		return &Failure{Seed: seed, Err: err}
+	}
+	return nil
+}
+
+// Explore runs test under n seeds, starting from first, and returns
+// the *Failure of the first seed for which it fails, or nil.
+func Explore(first uint64, n int, test func(s *Sim) error, opts ...Option) error {
+	for i := range n {
+		if err := Run(first+uint64(i), test, opts...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
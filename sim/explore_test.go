@@ -0,0 +1,64 @@
+package sim
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// lostUpdate runs two processes that increment a counter with a read,
+// an interleaving point, and a write, which loses updates under some
+// schedules.
+func lostUpdate(s *Sim) error {
+	counter := 0
+	for _, name := range []string{"a", "b"} {
+		s.Spawn(name, func(p *Proc) {
+			for range 2 {
+				v := counter
+				p.Yield()
+				counter = v + 1
+			}
+		})
+	}
+	if err := s.Run(); err != nil {
+		return err
+	}
+	if counter != 4 {
+		return fmt.Errorf("counter is %d", counter)
+	}
+	return nil
+}
+
+func TestExplore(t *testing.T) {
+	err := Explore(0, 100, lostUpdate, WithTrace())
+	var f *Failure
+	if !errors.As(err, &f) {
+		t.Fatalf("Expected a failing seed, got %v", err)
+	}
+
+	// Replaying the seed reproduces the failure and its trace.
+	var traces [][]string
+	for range 2 {
+		err := Run(f.Seed, func(s *Sim) error {
+			defer func() { traces = append(traces, s.Trace()) }()
+			return lostUpdate(s)
+		}, WithTrace())
+		if err == nil || err.Error() != f.Error() {
+			t.Errorf("Expected %v to reproduce, got %v", f, err)
+		}
+	}
+	if !reflect.DeepEqual(traces[0], traces[1]) || len(traces[0]) == 0 {
+		t.Errorf("Expected identical traces, got %v and %v", traces[0], traces[1])
+	}
+}
+
+func TestExplorePass(t *testing.T) {
+	err := Explore(0, 50, func(s *Sim) error {
+		s.Spawn("noop", func(p *Proc) { p.Yield() })
+		return s.Run()
+	})
+	if err != nil {
+		t.Errorf("Expected every seed to pass, got %v", err)
+	}
+}
@@ -0,0 +1,73 @@
+package sim
+
+import "time"
+
+// Mailbox is a queue of messages between simulated processes, such as
+// a process's inbox on a simulated network.
+type Mailbox[T any] struct {
+	s       *Sim
+	key     mailboxKey
+	queue   []T
+	waiters []*Proc
+}
+
+// NewMailbox returns an empty mailbox in s.
+func NewMailbox[T any](s *Sim) *Mailbox[T] {
+	s.mailboxes++
+	return &Mailbox[T]{s: s, key: mailboxKey(s.mailboxes)}
+}
+
+// Len returns the number of messages waiting in the mailbox.
+func (m *Mailbox[T]) Len() int {
+	return len(m.queue)
+}
+
+// Send delivers msg to the mailbox now, waking the processes waiting
+// for a message.
+func (m *Mailbox[T]) Send(msg T) {
+	m.s.Touch(m.key)
+	m.queue = append(m.queue, msg)
+	for _, p := range m.waiters {
+		p.Wake()
+	}
+	m.waiters = m.waiters[:0]
+}
+
+// SendAfter delivers msg to the mailbox once d of virtual time has
+// passed, as over a network with latency d. Messages sent with
+// different latencies can arrive out of order.
+func (m *Mailbox[T]) SendAfter(msg T, d time.Duration) {
+	m.s.Touch(m.key)
+	m.s.After(d, func() { m.Send(msg) })
+}
+
+// Recv returns the next message, parking p until one arrives.
+func (m *Mailbox[T]) Recv(p *Proc) T {
+	msg, _ := m.recv(p, time.Time{})
+	return msg
+}
+
+// RecvWithin returns the next message, parking p until one arrives or
+// d of virtual time has passed, in which case it returns false.
+func (m *Mailbox[T]) RecvWithin(p *Proc, d time.Duration) (T, bool) {
+	deadline := m.s.now.Add(d)
+	m.s.After(d, p.Wake)
+	return m.recv(p, deadline)
+}
+
+// recv waits for a message until deadline, if not zero.
+func (m *Mailbox[T]) recv(p *Proc, deadline time.Time) (T, bool) {
+	for m.s.Touch(m.key); len(m.queue) == 0; m.s.Touch(m.key) {
+		if !deadline.IsZero() && !m.s.now.Before(deadline) {
+			var zero T
+			return zero, false
+		}
+		m.waiters = append(m.waiters, p)
+		p.Park()
+	}
+	msg := m.queue[0]
+	var zero T
+	m.queue[0] = zero
+	m.queue = m.queue[1:]
+	return msg, true
+}
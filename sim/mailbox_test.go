@@ -0,0 +1,62 @@
+package sim
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMailbox(t *testing.T) {
+	s := New(1)
+	inbox := NewMailbox[string](s)
+	var received []string
+	var at []time.Duration
+	s.Spawn("server", func(p *Proc) {
+		for range 3 {
+			received = append(received, inbox.Recv(p))
+			at = append(at, s.Elapsed())
+		}
+	})
+	s.Spawn("client", func(p *Proc) {
+		inbox.SendAfter("slow", 2*time.Second)
+		inbox.SendAfter("fast", time.Second)
+		inbox.Send("now")
+	})
+	if err := s.Run(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if expected := []string{"now", "fast", "slow"}; !reflect.DeepEqual(received, expected) {
+		t.Errorf("Expected %v, got %v", expected, received)
+	}
+	if expected := []time.Duration{0, time.Second, 2 * time.Second}; !reflect.DeepEqual(at, expected) {
+		t.Errorf("Expected arrivals at %v, got %v", expected, at)
+	}
+	if inbox.Len() != 0 {
+		t.Errorf("Expected an empty mailbox, got %d", inbox.Len())
+	}
+}
+
+func TestMailboxRecvWithin(t *testing.T) {
+	s := New(1)
+	inbox := NewMailbox[int](s)
+	var results []bool
+	s.Spawn("waiter", func(p *Proc) {
+		_, ok := inbox.RecvWithin(p, time.Second)
+		results = append(results, ok)
+		msg, ok := inbox.RecvWithin(p, time.Second)
+		results = append(results, ok && msg == 7)
+	})
+	s.Spawn("sender", func(p *Proc) {
+		p.Sleep(1500 * time.Millisecond)
+		inbox.Send(7)
+	})
+	s.Run()
+
+	if !reflect.DeepEqual(results, []bool{false, true}) {
+		t.Errorf("Expected a timeout then a message, got %v", results)
+	}
+	if s.Elapsed() != 2*time.Second {
+		t.Errorf("Expected the stale timer to run out at 2s, got %v", s.Elapsed())
+	}
+}
@@ -0,0 +1,21 @@
+// Package sim runs whole systems of coroutines under deterministic
+// simulation, in the style of FoundationDB's simulation testing.
+//
+// Every process of the system is a coroutine, and coroutines only
+// switch at explicit points: when a process yields, sleeps, or waits
+// for a message. A Sim runs processes one at a time, choosing the next
+// one pseudo-randomly from a seed, and keeps virtual time that only
+// advances when every process is waiting for it, so hours of timeouts
+// and retries run in microseconds. Fault injection points and hooks
+// let a test crash processes and perturb the system, also driven by
+// the seed.
+//
+// A run is a pure function of its seed: the same seed reproduces the
+// same interleaving, faults and timings exactly, so a failure found by
+// Explore can be replayed and debugged with Run.
+//
+// For small tests, Check goes further than random seeds: it
+// enumerates the schedules of the processes depth first, optionally
+// with partial-order reduction, and reports the first schedule under
+// which the test fails.
+package sim
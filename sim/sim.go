@@ -0,0 +1,452 @@
+package sim
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"reflect"
+	"time"
+
+	"github.com/webriots/coro"
+	"github.com/webriots/coro/internal/task"
+)
+
+// Epoch is the virtual time at which simulations start by default.
+var Epoch = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+const (
+	runnable = task.Runnable
+	running  = task.Running
+	parked   = task.Parked
+	done     = task.Done
+)
+
+// Option configures a simulation.
+type Option func(*Sim)
+
+// WithStart sets the virtual time at which the simulation starts. The
+// default is Epoch.
+func WithStart(start time.Time) Option {
+	return func(s *Sim) {
+		s.now = start
+	}
+}
+
+// WithFaultRate sets the probability with which Fault reports that a
+// fault should be injected. The default is 0, disabling faults.
+func WithFaultRate(rate float64) Option {
+	return func(s *Sim) {
+		s.faultRate = rate
+	}
+}
+
+// WithHook registers fn to be called before each step with the
+// process about to run. Hooks inject faults into the system, for
+// example by killing processes with Kill, using Rand for their
+// decisions to keep the simulation deterministic.
+func WithHook(fn func(s *Sim, p *Proc)) Option {
+	return func(s *Sim) {
+		s.hooks = append(s.hooks, fn)
+	}
+}
+
+// WithTrace records a line describing each step and fault, returned
+// by Trace.
+func WithTrace() Option {
+	return func(s *Sim) {
+		s.tracing = true
+	}
+}
+
+// Sim is a deterministic simulation of a system of processes. A Sim
+// must only be used from one goroutine, and processes must only
+// interact through the simulation, not through goroutines or real
+// time.
+type Sim struct {
+	seed      uint64
+	rng       *rand.Rand
+	now       time.Time
+	start     time.Time
+	procs     []*Proc
+	runnable  []*Proc
+	choose    func(runnable []*Proc) int
+	footprint footprint
+	keys      map[any]objectKey
+	mailboxes int
+	observe   func(p *Proc, fp footprint)
+	timers    timers
+	seq       int
+	live      int
+	steps     int
+	faultRate float64
+	hooks     []func(*Sim, *Proc)
+	tracing   bool
+	trace     []string
+	err       error
+}
+
+// New returns a simulation whose choices are all derived from seed.
+func New(seed uint64, opts ...Option) *Sim {
+	s := &Sim{seed: seed, rng: rand.New(rand.NewPCG(seed, seed)), now: Epoch}
+	s.choose = func(runnable []*Proc) int { return s.rng.IntN(len(runnable)) }
+	for _, opt := range opts {
+		if opt != nil {
+			opt(s)
+		}
+	}
+	s.start = s.now
+	return s
+}
+
+// Seed returns the seed of the simulation.
+func (s *Sim) Seed() uint64 {
+	return s.seed
+}
+
+// Now returns the current virtual time.
+func (s *Sim) Now() time.Time {
+	return s.now
+}
+
+// Elapsed returns the virtual time elapsed since the simulation
+// started.
+func (s *Sim) Elapsed() time.Duration {
+	return s.now.Sub(s.start)
+}
+
+// Rand returns the simulation's source of randomness. Processes and
+// hooks must use it, rather than another source, for the simulation
+// to be reproducible.
+func (s *Sim) Rand() *rand.Rand {
+	return s.rng
+}
+
+// Steps returns the number of steps run so far.
+func (s *Sim) Steps() int {
+	return s.steps
+}
+
+// Live returns the number of processes that have not finished.
+func (s *Sim) Live() int {
+	return s.live
+}
+
+// Trace returns the steps and faults recorded with WithTrace.
+func (s *Sim) Trace() []string {
+	return s.trace
+}
+
+// tracef records a line in the trace, if tracing.
+func (s *Sim) tracef(format string, args ...any) {
+	if s.tracing {
+		s.trace = append(s.trace, fmt.Sprintf("%v %s", s.Elapsed(), fmt.Sprintf(format, args...)))
+	}
+}
+
+// Fault is a fault injection point: it reports, with the probability
+// set by WithFaultRate, that the fault called name should happen now.
+// Code under test calls it at points where a real system could fail,
+// such as dropping a message or failing a write.
+func (s *Sim) Fault(name string) bool {
+	if s.faultRate <= 0 || s.rng.Float64() >= s.faultRate {
+		return false
+	}
+	s.tracef("fault %s", name)
+	return true
+}
+
+// After calls fn on the simulation's loop once d of virtual time has
+// passed. Timers due at the same time run in the order they were set.
+//
+// For partial-order reduction, setting a timer touches the timer
+// queue, since the order of timers depends on the order of the steps
+// that set them, and fn runs under the footprint of the step that set
+// it, so that what fn touches makes that step dependent on others.
+func (s *Sim) After(d time.Duration, fn func()) {
+	s.Touch(timersKey{})
+	s.seq++
+	heap.Push(&s.timers, timer{at: s.now.Add(max(d, 0)), seq: s.seq, fn: fn, fp: s.footprint})
+}
+
+// Run runs the simulation until no process can run and no timer is
+// pending. Processes left waiting are not an error. If a process
+// panics, Run stops and returns the panic's error.
+func (s *Sim) Run() error {
+	return s.run(time.Time{})
+}
+
+// RunFor runs the simulation like Run, but stops once d of virtual
+// time has passed since the start of the call.
+func (s *Sim) RunFor(d time.Duration) error {
+	return s.run(s.now.Add(d))
+}
+
+// run runs the simulation until it is quiescent or, if limit is not
+// zero, virtual time would pass limit.
+func (s *Sim) run(limit time.Time) error {
+	for s.err == nil {
+		if len(s.runnable) == 0 {
+			if len(s.timers) == 0 {
+				break
+			}
+			next := s.timers[0].at
+			if !limit.IsZero() && next.After(limit) {
+				s.now = limit
+				break
+			}
+			s.now = next
+			for len(s.timers) > 0 && !s.timers[0].at.After(s.now) {
+				t := heap.Pop(&s.timers).(timer)
+				s.footprint = t.fp
+				t.fn()
+				s.footprint = nil
+			}
+			continue
+		}
+		i := s.choose(s.runnable)
+		p := s.runnable[i]
+		last := len(s.runnable) - 1
+		s.runnable[i], s.runnable[last] = s.runnable[last], nil
+		s.runnable = s.runnable[:last]
+		s.step(p)
+	}
+	return s.err
+}
+
+// step runs p until it yields, parks or finishes.
+func (s *Sim) step(p *Proc) {
+	s.steps++
+	for _, fn := range s.hooks {
+		fn(s, p)
+	}
+	if p.co.State != runnable {
+		return
+	}
+	s.tracef("run %s", p.name)
+	s.footprint = footprint{procKey(p.id): {}}
+	if finished, err := p.co.Step(); finished {
+		p.finish(err)
+	}
+	if s.observe != nil {
+		s.observe(p, s.footprint)
+	}
+	s.footprint = nil
+}
+
+// Touch declares that the running process accesses the shared object
+// key in its current step. Check uses these declarations for
+// partial-order reduction: steps of different processes that touch
+// no common object are assumed not to affect each other. Mailboxes
+// declare their own accesses.
+//
+// Since Check compares steps across executions, keys are best values
+// that name the object the same way in every execution, such as
+// strings. Pointers are accepted, but can only be numbered by the
+// order in which each execution first touches them, which makes the
+// reduction less effective.
+func (s *Sim) Touch(key any) {
+	if s.footprint == nil {
+		return
+	}
+	if k := reflect.ValueOf(key).Kind(); k == reflect.Pointer || k == reflect.UnsafePointer ||
+		k == reflect.Chan || k == reflect.Map || k == reflect.Func {
+		if s.keys == nil {
+			s.keys = make(map[any]objectKey)
+		}
+		id, ok := s.keys[key]
+		if !ok {
+			id = objectKey(len(s.keys))
+			s.keys[key] = id
+		}
+		key = id
+	}
+	s.footprint[key] = struct{}{}
+}
+
+// Close kills every live process, so that no coroutine of the
+// simulation is left suspended, and discards pending timers.
+func (s *Sim) Close() {
+	for _, p := range s.procs {
+		s.Kill(p)
+	}
+	s.runnable, s.timers = nil, nil
+}
+
+// Proc is a process of a simulation.
+type Proc struct {
+	s    *Sim
+	id   int
+	name string
+	co   task.Task
+	err  error
+}
+
+// Spawn creates a process called name running fn and makes it
+// runnable. Options are passed through to coro.New.
+func (s *Sim) Spawn(name string, fn func(p *Proc), opts ...coro.Option) *Proc {
+	p := &Proc{s: s, id: len(s.procs), name: name}
+	p.co.Start(func() { fn(p) }, opts)
+	s.live++
+	s.procs = append(s.procs, p)
+	s.runnable = append(s.runnable, p)
+	return p
+}
+
+// finish records that p has finished with err. A panic stops the
+// simulation.
+func (p *Proc) finish(err error) {
+	p.co.State, p.err = done, err
+	p.s.live--
+	if err != nil && !errors.Is(err, coro.ErrCanceled) {
+		p.s.err = fmt.Errorf("sim: process %s: %w", p.name, err)
+	}
+}
+
+// Name returns the name of the process.
+func (p *Proc) Name() string {
+	return p.name
+}
+
+// Sim returns the simulation the process belongs to.
+func (p *Proc) Sim() *Sim {
+	return p.s
+}
+
+// Done reports whether the process has finished or been killed.
+func (p *Proc) Done() bool {
+	return p.co.State == done
+}
+
+// Err returns the error the process finished with: nil if it
+// returned, an error wrapping coro.ErrCanceled if it was killed, or
+// the error of its panic.
+func (p *Proc) Err() error {
+	return p.err
+}
+
+// Yield is an interleaving point: it lets the simulation run any
+// other runnable process before p continues. It must only be called
+// by p itself.
+func (p *Proc) Yield() {
+	p.s.runnable = append(p.s.runnable, p)
+	p.co.Yield()
+}
+
+// Sleep suspends p for d of virtual time. It must only be called by p
+// itself.
+func (p *Proc) Sleep(d time.Duration) {
+	wake := p.s.now.Add(d)
+	p.s.After(d, p.Wake)
+	for p.s.now.Before(wake) {
+		p.Park()
+	}
+}
+
+// Park suspends p until it is woken by Wake. If p was woken since it
+// last parked, Park returns immediately. Wakeups can be spurious, so
+// callers park in a loop until the condition they wait for holds. It
+// must only be called by p itself.
+func (p *Proc) Park() {
+	p.co.Park()
+}
+
+// Wake makes a parked process runnable again. Waking a process that
+// is not parked makes its next Park return immediately; waking a
+// finished process does nothing.
+func (p *Proc) Wake() {
+	p.s.Touch(procKey(p.id))
+	if p.co.Wake() {
+		p.s.runnable = append(p.s.runnable, p)
+	}
+}
+
+// Kill crashes p, unwinding its coroutine. When called by p itself,
+// Kill does not return.
+func (s *Sim) Kill(p *Proc) {
+	switch p.co.State {
+	case done:
+		return
+	case running:
+		panic(fmt.Errorf("%w", coro.ErrCanceled))
+	}
+	s.tracef("kill %s", p.name)
+	if i := indexOf(s.runnable, p); i >= 0 {
+		s.runnable = append(s.runnable[:i], s.runnable[i+1:]...)
+	}
+	p.finish(p.co.Unwind(fmt.Errorf("%w", coro.ErrCanceled)))
+}
+
+// indexOf returns the index of p in procs, or -1.
+func indexOf(procs []*Proc, p *Proc) int {
+	for i, q := range procs {
+		if q == p {
+			return i
+		}
+	}
+	return -1
+}
+
+// footprint is the set of objects a step touched, including those
+// touched by the timers it set. Processes are identified by their
+// spawn index, mailboxes by their creation index, and pointers by the order in which the execution first
+// touched them, so that footprints can be compared across executions
+// that share a prefix. Numbering pointers by first touch can make
+// steps that touch different new objects look dependent, but never
+// makes dependent steps look independent.
+type footprint map[any]struct{}
+
+// procKey identifies a process in a footprint.
+type procKey int
+
+// mailboxKey identifies a mailbox in a footprint.
+type mailboxKey int
+
+// objectKey identifies a pointer in a footprint.
+type objectKey int
+
+// timersKey identifies the timer queue in a footprint.
+type timersKey struct{}
+
+// independent reports whether two footprints have no object in
+// common.
+func (fp footprint) independent(other footprint) bool {
+	for key := range fp {
+		if _, ok := other[key]; ok {
+			return false
+		}
+	}
+	return true
+}
+
+// timer is a pending call to After, with the footprint of the step
+// that set it.
+type timer struct {
+	at  time.Time
+	seq int
+	fn  func()
+	fp  footprint
+}
+
+// timers is a min-heap of timers ordered by due time and creation.
+type timers []timer
+
+func (t timers) Len() int { return len(t) }
+
+func (t timers) Less(i, j int) bool {
+	if !t[i].at.Equal(t[j].at) {
+		return t[i].at.Before(t[j].at)
+	}
+	return t[i].seq < t[j].seq
+}
+
+func (t timers) Swap(i, j int) { t[i], t[j] = t[j], t[i] }
+
+func (t *timers) Push(x any) { *t = append(*t, x.(timer)) }
+
+func (t *timers) Pop() any {
+	old := *t
+	x := old[len(old)-1]
+	*t = old[:len(old)-1]
+	return x
+}
@@ -0,0 +1,189 @@
+package sim
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/webriots/coro"
+)
+
+// interleave spawns three processes that each record three steps,
+// yielding between them, and returns the order they ran in.
+func interleave(seed uint64) []string {
+	s := New(seed)
+	var order []string
+	for _, name := range []string{"a", "b", "c"} {
+		s.Spawn(name, func(p *Proc) {
+			for range 3 {
+				order = append(order, p.Name())
+				p.Yield()
+			}
+		})
+	}
+	s.Run()
+	return order
+}
+
+func TestDeterminism(t *testing.T) {
+	first := interleave(1)
+	if len(first) != 9 {
+		t.Fatalf("Expected 9 steps, got %v", first)
+	}
+	if again := interleave(1); !reflect.DeepEqual(first, again) {
+		t.Errorf("Expected the same seed to give the same order, got %v and %v", first, again)
+	}
+
+	orders := make(map[string]bool)
+	for seed := range uint64(20) {
+		orders[strings.Join(interleave(seed), "")] = true
+	}
+	if len(orders) < 5 {
+		t.Errorf("Expected different seeds to explore different orders, got %d", len(orders))
+	}
+}
+
+func TestVirtualTime(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := New(1, WithStart(start))
+	var woke []time.Duration
+	for _, d := range []time.Duration{time.Hour, time.Second, time.Minute} {
+		s.Spawn("sleeper", func(p *Proc) {
+			p.Sleep(d)
+			woke = append(woke, s.Elapsed())
+		})
+	}
+
+	began := time.Now()
+	if err := s.Run(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if time.Since(began) > time.Second {
+		t.Error("Expected virtual time not to take real time")
+	}
+	if expected := []time.Duration{time.Second, time.Minute, time.Hour}; !reflect.DeepEqual(woke, expected) {
+		t.Errorf("Expected wakeups at %v, got %v", expected, woke)
+	}
+	if !s.Now().Equal(start.Add(time.Hour)) {
+		t.Errorf("Expected the clock at %v, got %v", start.Add(time.Hour), s.Now())
+	}
+}
+
+func TestRunFor(t *testing.T) {
+	s := New(1)
+	var ticks int
+	s.Spawn("ticker", func(p *Proc) {
+		for {
+			p.Sleep(time.Second)
+			ticks++
+		}
+	})
+
+	s.RunFor(10*time.Second + time.Millisecond)
+	if ticks != 10 || s.Elapsed() != 10*time.Second+time.Millisecond {
+		t.Errorf("Expected 10 ticks in 10s, got %d in %v", ticks, s.Elapsed())
+	}
+	if s.Live() != 1 {
+		t.Errorf("Expected the ticker to be live, got %d", s.Live())
+	}
+}
+
+func TestParkWake(t *testing.T) {
+	s := New(1)
+	var ready bool
+	waiter := s.Spawn("waiter", func(p *Proc) {
+		for !ready {
+			p.Park()
+		}
+	})
+	s.Run()
+	if waiter.Done() {
+		t.Fatal("Expected the waiter to be parked")
+	}
+
+	s.Spawn("waker", func(p *Proc) {
+		ready = true
+		waiter.Wake()
+	})
+	s.Run()
+	if !waiter.Done() || waiter.Err() != nil || waiter.Sim() != s {
+		t.Error("Expected the waiter to finish")
+	}
+}
+
+func TestPanic(t *testing.T) {
+	s := New(1)
+	var ran int
+	s.Spawn("bad", func(p *Proc) { panic("boom") })
+	s.Spawn("good", func(p *Proc) {
+		for {
+			ran++
+			p.Yield()
+		}
+	})
+
+	err := s.Run()
+	if err == nil || err.Error() != "sim: process bad: boom" {
+		t.Errorf("Expected the process's panic, got %v", err)
+	}
+	if err2 := s.Run(); err2 != err {
+		t.Errorf("Expected the simulation to stay failed, got %v", err2)
+	}
+}
+
+func TestKillHook(t *testing.T) {
+	var killed []string
+	s := New(3, WithTrace(), WithHook(func(s *Sim, p *Proc) {
+		if p.Name() == "victim" && s.Steps() > 2 {
+			s.Kill(p)
+			killed = append(killed, p.Name())
+		}
+	}))
+	var unwound bool
+	victim := s.Spawn("victim", func(p *Proc) {
+		defer func() { unwound = true }()
+		for {
+			p.Yield()
+		}
+	})
+	s.Spawn("suicide", func(p *Proc) {
+		s.Kill(p)
+		t.Error("Expected Kill not to return")
+	})
+
+	if err := s.Run(); err != nil {
+		t.Fatalf("Expected killing not to fail the simulation, got %v", err)
+	}
+	if !unwound || !victim.Done() || !errors.Is(victim.Err(), coro.ErrCanceled) || len(killed) != 1 {
+		t.Errorf("Expected the victim to be killed once, got %v", killed)
+	}
+	if !reflect.DeepEqual(s.Trace()[len(s.Trace())-1], "0s kill victim") {
+		t.Errorf("Expected the kill to be traced, got %v", s.Trace())
+	}
+}
+
+func TestFault(t *testing.T) {
+	s := New(1)
+	if s.Fault("never") {
+		t.Error("Expected no faults by default")
+	}
+
+	s = New(1, WithFaultRate(0.5), WithTrace())
+	var faults int
+	for range 1000 {
+		if s.Fault("drop") {
+			faults++
+		}
+	}
+	if faults < 400 || faults > 600 {
+		t.Errorf("Expected about 500 faults, got %d", faults)
+	}
+	if len(s.Trace()) != faults || s.Trace()[0] != "0s fault drop" {
+		t.Errorf("Expected faults to be traced, got %d lines", len(s.Trace()))
+	}
+	if s.Seed() != 1 {
+		t.Errorf("Expected seed 1, got %d", s.Seed())
+	}
+}
@@ -0,0 +1,384 @@
+package coro
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/webriots/coro/internal/panics"
+)
+
+var (
+	// ErrTimeout is returned by the timed waits of a state machine
+	// when their deadline passes before a matching event arrives.
+	ErrTimeout = errors.New("coro: state machine wait timed out")
+
+	// ErrMachineDone is returned when an event is sent to a state
+	// machine that has finished.
+	ErrMachineDone = errors.New("coro: state machine done")
+)
+
+// signalKind says why a state machine's coroutine was resumed.
+type signalKind int
+
+const (
+	signalContinue signalKind = iota
+	signalEvent
+	signalTimeout
+)
+
+// signal is what a state machine's coroutine is resumed with.
+type signal[Event any] struct {
+	kind  signalKind
+	event Event
+}
+
+// machineStep is what a state machine's coroutine hands back: either
+// an output, or, when the body returns, its error. Parking to wait for
+// an event hands back the zero step.
+type machineStep[Output any] struct {
+	out Output
+	ok  bool
+	err error
+}
+
+// Parked describes the point at which a state machine is waiting for
+// an event.
+type Parked struct {
+	// State is the name set with Machine.State, if any.
+	State string
+	// Op is the wait the machine is parked in, such as "Next" or
+	// "Expect".
+	Op string
+	// Function, File and Line locate the call to the wait.
+	Function string
+	File     string
+	Line     int
+	// Deadline is when a timed wait times out, or the zero time.
+	Deadline time.Time
+}
+
+// String describes the parked point, for example
+// "awaiting-ack: Expect at handshake.go:42".
+func (p Parked) String() string {
+	s := fmt.Sprintf("%s at %s", p.Op, p.location())
+	if p.State != "" {
+		s = p.State + ": " + s
+	}
+	if !p.Deadline.IsZero() {
+		s += " (deadline " + p.Deadline.Format(time.RFC3339Nano) + ")"
+	}
+	return s
+}
+
+// location returns the parked point's file and line.
+func (p Parked) location() string {
+	return filepath.Base(p.File) + ":" + strconv.Itoa(p.Line)
+}
+
+// node returns the name of the parked point in a state machine's
+// graph.
+func (p Parked) node() string {
+	if p.State != "" {
+		return p.State
+	}
+	return p.location()
+}
+
+// Machine is the handle a state machine's body uses to wait for
+// events and emit outputs. It must only be used from within the body.
+type Machine[Event, Output any] struct {
+	sm    *StateMachine[Event, Output]
+	yield func(machineStep[Output]) signal[Event]
+	state string
+}
+
+// Emit produces out from the state machine. Outputs are returned by
+// the Start, Send or Tick call that is driving the machine.
+func (m *Machine[Event, Output]) Emit(out Output) {
+	m.yield(machineStep[Output]{out: out, ok: true})
+}
+
+// State names the state the machine is in, for Parked and the graph
+// written by WriteDot. It remains in effect until changed.
+func (m *Machine[Event, Output]) State(name string) {
+	m.state = name
+}
+
+// Now returns the current time of the machine's clock.
+func (m *Machine[Event, Output]) Now() time.Time {
+	return m.sm.clock.Now()
+}
+
+// Next parks the machine until the next event arrives and returns it.
+func (m *Machine[Event, Output]) Next() Event {
+	ev, _ := m.wait("Next", nil, 0, false)
+	return ev
+}
+
+// Expect parks the machine until an event for which pred returns true
+// arrives, discarding any others, and returns it.
+func (m *Machine[Event, Output]) Expect(pred func(Event) bool) Event {
+	ev, _ := m.wait("Expect", pred, 0, false)
+	return ev
+}
+
+// NextWithin is like Next, but returns ErrTimeout if no event has
+// arrived by the time d has passed on the machine's clock.
+func (m *Machine[Event, Output]) NextWithin(d time.Duration) (Event, error) {
+	return m.wait("NextWithin", nil, d, true)
+}
+
+// ExpectWithin is like Expect, but returns ErrTimeout if no matching
+// event has arrived by the time d has passed on the machine's clock.
+func (m *Machine[Event, Output]) ExpectWithin(d time.Duration, pred func(Event) bool) (Event, error) {
+	return m.wait("ExpectWithin", pred, d, true)
+}
+
+// wait parks the machine at its caller's caller until an event
+// matching pred arrives or, if timed, the deadline passes.
+func (m *Machine[Event, Output]) wait(op string, pred func(Event) bool, d time.Duration, timed bool) (Event, error) {
+	p := Parked{State: m.state, Op: op}
+	pc, file, line, _ := runtime.Caller(2)
+	p.File, p.Line = file, line
+	if f := runtime.FuncForPC(pc); f != nil {
+		p.Function = f.Name()
+	}
+	if timed {
+		p.Deadline = m.sm.clock.Now().Add(d)
+	}
+	m.sm.park(p)
+	defer func() { m.sm.parked = nil }()
+
+	for {
+		sig := m.yield(machineStep[Output]{})
+		switch sig.kind {
+		case signalTimeout:
+			var zero Event
+			return zero, ErrTimeout
+		case signalEvent:
+			if pred == nil || pred(sig.event) {
+				return sig.event, nil
+			}
+		}
+	}
+}
+
+// edge is a transition observed between two parked points of a state
+// machine.
+type edge struct {
+	from, to string
+}
+
+// maxEdgeLabels is how many distinct triggers are listed on an edge
+// of a state machine's graph.
+const maxEdgeLabels = 3
+
+// StateMachine runs a state machine whose body is written as straight
+// line code: it waits for events with Next and Expect and produces
+// outputs with Emit, while the caller feeds it events with Send. Each
+// call returns the outputs produced until the machine parks again.
+//
+// Timed waits use the machine's clock, and time out the next time the
+// machine is driven after their deadline. A driver can call Tick when
+// the deadline reported by Deadline passes to time out a wait without
+// sending an event.
+//
+// A StateMachine must not be used from multiple goroutines at once.
+type StateMachine[Event, Output any] struct {
+	resume  func(signal[Event]) (machineStep[Output], bool)
+	cancel  func()
+	clock   Clock
+	parked  *Parked
+	started bool
+	done    bool
+	err     error
+
+	last    string
+	trigger string
+	edges   map[edge][]string
+	order   []edge
+}
+
+// NewStateMachine returns a state machine running fn. Timed waits use
+// clock, or SystemClock if clock is nil. The body does not run until
+// the machine is started by Start, Send or Tick. If fn returns, the
+// machine is done, and its error is returned by the call driving it.
+// Options are passed through to New.
+func NewStateMachine[Event, Output any](
+	fn func(m *Machine[Event, Output]) error,
+	clock Clock,
+	opts ...Option,
+) *StateMachine[Event, Output] {
+	if clock == nil {
+		clock = SystemClock
+	}
+	sm := &StateMachine[Event, Output]{
+		clock: clock,
+		last:  "start",
+		edges: make(map[edge][]string),
+	}
+	sm.resume, sm.cancel = New(func(yield func(machineStep[Output]) signal[Event], _ func() signal[Event]) machineStep[Output] {
+		return machineStep[Output]{err: fn(&Machine[Event, Output]{sm: sm, yield: yield})}
+	}, opts...)
+	return sm
+}
+
+// Start runs the machine until it first parks, returning the outputs
+// it emits along the way. Calling Start on a started machine does
+// nothing.
+func (sm *StateMachine[Event, Output]) Start() ([]Output, error) {
+	if sm.started {
+		return nil, nil
+	}
+	sm.started = true
+	return sm.run(signal[Event]{kind: signalContinue})
+}
+
+// Send delivers ev to the machine and runs it until it parks again,
+// returning the outputs it emits along the way. If the machine's
+// deadline has passed, its wait times out before ev is delivered. If
+// the machine has finished, Send returns its error, or ErrMachineDone
+// if that was nil. If the body panics, Send propagates the panic.
+func (sm *StateMachine[Event, Output]) Send(ev Event) ([]Output, error) {
+	outs, err := sm.Tick()
+	if err != nil {
+		return outs, err
+	}
+	if sm.done {
+		return outs, sm.doneErr()
+	}
+	sm.trigger = fmt.Sprint(ev)
+	more, err := sm.run(signal[Event]{kind: signalEvent, event: ev})
+	return append(outs, more...), err
+}
+
+// Tick starts the machine if needed and, if the deadline of its wait
+// has passed, times the wait out and runs the machine until it parks
+// again, returning the outputs it emits.
+func (sm *StateMachine[Event, Output]) Tick() ([]Output, error) {
+	outs, err := sm.Start()
+	if err != nil || sm.done {
+		return outs, err
+	}
+	if deadline, ok := sm.Deadline(); ok && !sm.clock.Now().Before(deadline) {
+		sm.trigger = "timeout"
+		more, err := sm.run(signal[Event]{kind: signalTimeout})
+		return append(outs, more...), err
+	}
+	return outs, nil
+}
+
+// Deadline returns the deadline of the timed wait the machine is
+// parked in, if any.
+func (sm *StateMachine[Event, Output]) Deadline() (time.Time, bool) {
+	if sm.parked == nil || sm.parked.Deadline.IsZero() {
+		return time.Time{}, false
+	}
+	return sm.parked.Deadline, true
+}
+
+// Parked returns the point at which the machine is waiting for an
+// event. It returns false if the machine has not started or is done.
+func (sm *StateMachine[Event, Output]) Parked() (Parked, bool) {
+	if sm.parked == nil {
+		return Parked{}, false
+	}
+	return *sm.parked, true
+}
+
+// Done reports whether the machine's body has returned.
+func (sm *StateMachine[Event, Output]) Done() bool {
+	return sm.done
+}
+
+// Stop abandons the machine, canceling its coroutine.
+func (sm *StateMachine[Event, Output]) Stop() {
+	if sm.done {
+		return
+	}
+	sm.done, sm.parked = true, nil
+	panics.Cancel(sm.cancel)
+}
+
+// WriteDot writes the transitions the machine has made so far as a
+// Graphviz DOT graph. Nodes are the points the machine parked at,
+// named by their state or else their location, and edges are labeled
+// with the events or timeouts that moved the machine between them.
+func (sm *StateMachine[Event, Output]) WriteDot(w io.Writer) error {
+	var sb strings.Builder
+	sb.WriteString("digraph {\n")
+	for _, e := range sm.order {
+		labels := sm.edges[e]
+		label := strings.Join(labels[:min(len(labels), maxEdgeLabels)], "\n")
+		if len(labels) > maxEdgeLabels {
+			label += "\n..."
+		}
+		fmt.Fprintf(&sb, "\t%s -> %s", strconv.Quote(e.from), strconv.Quote(e.to))
+		if label != "" {
+			fmt.Fprintf(&sb, " [label=%s]", strconv.Quote(label))
+		}
+		sb.WriteString(";\n")
+	}
+	if sm.parked != nil {
+		fmt.Fprintf(&sb, "\t%s [style=bold];\n", strconv.Quote(sm.parked.node()))
+	}
+	sb.WriteString("}\n")
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// run resumes the machine with sig until it parks or finishes.
+func (sm *StateMachine[Event, Output]) run(sig signal[Event]) ([]Output, error) {
+	var outs []Output
+	for {
+		s, running := sm.resume(sig)
+		switch {
+		case s.ok:
+			outs = append(outs, s.out)
+			sig = signal[Event]{kind: signalContinue}
+		case running:
+			return outs, nil
+		default:
+			sm.done, sm.err = true, s.err
+			sm.transition("done")
+			return outs, s.err
+		}
+	}
+}
+
+// park records that the machine is waiting at p.
+func (sm *StateMachine[Event, Output]) park(p Parked) {
+	sm.parked = &p
+	sm.transition(p.node())
+}
+
+// transition records a move from the last parked point to node,
+// triggered by the last event or timeout.
+func (sm *StateMachine[Event, Output]) transition(node string) {
+	e := edge{from: sm.last, to: node}
+	labels, seen := sm.edges[e]
+	if !seen {
+		sm.order = append(sm.order, e)
+	}
+	if sm.trigger != "" && !slices.Contains(labels, sm.trigger) {
+		labels = append(labels, sm.trigger)
+	}
+	sm.edges[e] = labels
+	sm.last, sm.trigger = node, ""
+}
+
+// doneErr returns the error reported by Send once the machine has
+// finished.
+func (sm *StateMachine[Event, Output]) doneErr() error {
+	if sm.err != nil {
+		return sm.err
+	}
+	return ErrMachineDone
+}
@@ -0,0 +1,216 @@
+package coro
+
+import (
+	"errors"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// handshake is a client handshake: it says hello, waits for the
+// server's hello (ignoring anything else), then waits for an ack
+// within a second, retrying once.
+func handshake(m *Machine[string, string]) error {
+	m.State("greeting")
+	m.Emit("HELLO")
+	m.Expect(func(ev string) bool { return ev == "HELLO" })
+
+	m.State("awaiting-ack")
+	for attempt := 0; ; attempt++ {
+		m.Emit("AUTH")
+		ev, err := m.NextWithin(time.Second)
+		if err == ErrTimeout && attempt == 0 {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if ev != "ACK" {
+			return errors.New("unexpected " + ev)
+		}
+		m.Emit("READY")
+		return nil
+	}
+}
+
+func expectOutputs(t *testing.T, outs []string, err error, expected ...string) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !slices.Equal(outs, expected) {
+		t.Fatalf("Expected outputs %v, got %v", expected, outs)
+	}
+}
+
+func TestStateMachine(t *testing.T) {
+	sm := NewStateMachine(handshake, nil)
+	defer sm.Stop()
+
+	if _, ok := sm.Parked(); ok {
+		t.Error("Expected an unstarted machine not to be parked")
+	}
+
+	outs, err := sm.Start()
+	expectOutputs(t, outs, err, "HELLO")
+
+	outs, err = sm.Send("NOISE")
+	expectOutputs(t, outs, err)
+
+	outs, err = sm.Send("HELLO")
+	expectOutputs(t, outs, err, "AUTH")
+
+	outs, err = sm.Send("ACK")
+	expectOutputs(t, outs, err, "READY")
+
+	if !sm.Done() {
+		t.Error("Expected the machine to be done")
+	}
+	if _, err := sm.Send("HELLO"); err != ErrMachineDone {
+		t.Errorf("Expected ErrMachineDone, got %v", err)
+	}
+}
+
+func TestStateMachineError(t *testing.T) {
+	sm := NewStateMachine(handshake, nil)
+	sm.Send("HELLO")
+
+	if _, err := sm.Send("NACK"); err == nil || err.Error() != "unexpected NACK" {
+		t.Errorf("Expected the body's error, got %v", err)
+	}
+	if _, err := sm.Send("ACK"); err == nil || err.Error() != "unexpected NACK" {
+		t.Errorf("Expected the body's error again, got %v", err)
+	}
+}
+
+func TestStateMachineTimeout(t *testing.T) {
+	clock := NewManualClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	sm := NewStateMachine(handshake, clock)
+	defer sm.Stop()
+
+	outs, err := sm.Send("HELLO")
+	expectOutputs(t, outs, err, "HELLO", "AUTH")
+
+	deadline, ok := sm.Deadline()
+	if !ok || !deadline.Equal(clock.Now().Add(time.Second)) {
+		t.Errorf("Expected a deadline in one second, got %v, %v", deadline, ok)
+	}
+
+	clock.Advance(time.Second - 1)
+	outs, err = sm.Tick()
+	expectOutputs(t, outs, err)
+
+	// The first timeout retries.
+	clock.Advance(1)
+	outs, err = sm.Tick()
+	expectOutputs(t, outs, err, "AUTH")
+
+	// The second times out before the late ack is delivered.
+	clock.Advance(time.Second)
+	outs, err = sm.Send("ACK")
+	if err != ErrTimeout || outs != nil {
+		t.Errorf("Expected ErrTimeout, got %v, %v", outs, err)
+	}
+}
+
+func TestStateMachineParked(t *testing.T) {
+	clock := NewManualClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	sm := NewStateMachine(handshake, clock)
+	defer sm.Stop()
+
+	sm.Start()
+	p, ok := sm.Parked()
+	if !ok {
+		t.Fatal("Expected the machine to be parked")
+	}
+	if p.State != "greeting" || p.Op != "Expect" || !strings.HasSuffix(p.File, "statemachine_test.go") {
+		t.Errorf("Expected to be parked in Expect in the greeting state, got %+v", p)
+	}
+	if !strings.HasSuffix(p.Function, ".handshake") {
+		t.Errorf("Expected to be parked in handshake, got %s", p.Function)
+	}
+	if s := p.String(); !strings.HasPrefix(s, "greeting: Expect at statemachine_test.go:") {
+		t.Errorf("Expected a description of the parked point, got %s", s)
+	}
+
+	sm.Send("HELLO")
+	p, _ = sm.Parked()
+	if s := p.String(); !strings.HasPrefix(s, "awaiting-ack: NextWithin at statemachine_test.go:") ||
+		!strings.HasSuffix(s, "(deadline 2024-01-01T00:00:01Z)") {
+		t.Errorf("Expected a description of the timed wait, got %s", s)
+	}
+}
+
+func TestStateMachineDot(t *testing.T) {
+	clock := NewManualClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	sm := NewStateMachine(handshake, clock)
+	defer sm.Stop()
+
+	sm.Send("HELLO")
+	clock.Advance(time.Second)
+	sm.Tick()
+
+	var sb strings.Builder
+	if err := sm.WriteDot(&sb); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	expected := `digraph {
+	"start" -> "greeting";
+	"greeting" -> "awaiting-ack" [label="HELLO"];
+	"awaiting-ack" -> "awaiting-ack" [label="timeout"];
+	"awaiting-ack" [style=bold];
+}
+`
+	if sb.String() != expected {
+		t.Errorf("Expected graph:\n%s\ngot:\n%s", expected, sb.String())
+	}
+
+	sm.Send("ACK")
+	sb.Reset()
+	sm.WriteDot(&sb)
+	if !strings.Contains(sb.String(), `"awaiting-ack" -> "done" [label="ACK"];`) {
+		t.Errorf("Expected a transition to done, got:\n%s", sb.String())
+	}
+}
+
+func TestStateMachineStop(t *testing.T) {
+	var unwound bool
+	sm := NewStateMachine(func(m *Machine[int, int]) error {
+		defer func() { unwound = true }()
+		for {
+			m.Emit(m.Next() * 2)
+		}
+	}, nil)
+
+	outs, _ := sm.Send(21)
+	if !reflect.DeepEqual(outs, []int{42}) {
+		t.Errorf("Expected [42], got %v", outs)
+	}
+
+	sm.Stop()
+	if !unwound {
+		t.Error("Expected the body to be unwound")
+	}
+	if _, ok := sm.Parked(); ok {
+		t.Error("Expected a stopped machine not to be parked")
+	}
+	if _, err := sm.Send(1); err != ErrMachineDone {
+		t.Errorf("Expected ErrMachineDone, got %v", err)
+	}
+}
+
+func TestStateMachinePanic(t *testing.T) {
+	sm := NewStateMachine(func(m *Machine[int, int]) error {
+		m.Next()
+		panic("test panic")
+	}, nil)
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("Expected panic but got none")
+		}
+	}()
+	sm.Send(1)
+}
@@ -0,0 +1,146 @@
+package stream
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"unicode/utf8"
+)
+
+// ErrUnread is returned by UnreadByte when there is no byte to unread.
+var ErrUnread = errors.New("stream: no byte to unread")
+
+// Input is the input of a tokenizer. It holds the bytes that have
+// been fed to the tokenizer but not yet consumed. Reading beyond them
+// suspends the tokenizer until more bytes are fed, so reads only fail
+// with io.EOF once the input has been closed.
+//
+// Input implements io.Reader, io.ByteScanner and io.RuneReader, and
+// must only be used from within the tokenizer function.
+type Input struct {
+	buf     []byte
+	off     int
+	eof     bool
+	suspend func()
+}
+
+// fill suspends until at least n bytes are buffered or the input is
+// closed, and reports whether n bytes are available.
+func (in *Input) fill(n int) bool {
+	for in.Buffered() < n && !in.eof {
+		in.suspend()
+	}
+	return in.Buffered() >= n
+}
+
+// push appends p to the buffered bytes, first discarding consumed
+// bytes other than the last one, which is kept for UnreadByte.
+func (in *Input) push(p []byte) {
+	if keep := in.off - 1; keep > 0 {
+		n := copy(in.buf, in.buf[keep:])
+		in.buf, in.off = in.buf[:n], 1
+	}
+	in.buf = append(in.buf, p...)
+}
+
+// Buffered returns the number of bytes that can be read without
+// suspending.
+func (in *Input) Buffered() int {
+	return len(in.buf) - in.off
+}
+
+// ReadByte returns the next byte, suspending until one is available.
+// It returns io.EOF if the input is closed and fully consumed.
+func (in *Input) ReadByte() (byte, error) {
+	if !in.fill(1) {
+		return 0, io.EOF
+	}
+	b := in.buf[in.off]
+	in.off++
+	return b, nil
+}
+
+// UnreadByte unreads the last byte read.
+func (in *Input) UnreadByte() error {
+	if in.off == 0 {
+		return ErrUnread
+	}
+	in.off--
+	return nil
+}
+
+// ReadRune returns the next UTF-8 encoded rune and its size in
+// bytes, suspending until the whole rune is available. Invalid
+// encodings are returned as utf8.RuneError with a size of 1.
+func (in *Input) ReadRune() (rune, int, error) {
+	for !utf8.FullRune(in.buf[in.off:]) && !in.eof {
+		in.suspend()
+	}
+	if in.Buffered() == 0 {
+		return 0, 0, io.EOF
+	}
+	r, size := utf8.DecodeRune(in.buf[in.off:])
+	in.off += size
+	return r, size, nil
+}
+
+// Read reads up to len(p) bytes into p, suspending until at least one
+// byte is available. It returns io.EOF if the input is closed and
+// fully consumed.
+func (in *Input) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if !in.fill(1) {
+		return 0, io.EOF
+	}
+	n := copy(p, in.buf[in.off:])
+	in.off += n
+	return n, nil
+}
+
+// Peek returns the next n bytes without consuming them, suspending
+// until they are available. If the input is closed first, Peek
+// returns the remaining bytes and io.EOF. The result is only valid
+// until the next read.
+func (in *Input) Peek(n int) ([]byte, error) {
+	if !in.fill(n) {
+		return in.buf[in.off:], io.EOF
+	}
+	return in.buf[in.off : in.off+n], nil
+}
+
+// Next consumes and returns the next n bytes, suspending until they
+// are available. If the input is closed first, Next consumes and
+// returns the remaining bytes and io.ErrUnexpectedEOF, or io.EOF if
+// there were none. The result is a newly allocated slice.
+func (in *Input) Next(n int) ([]byte, error) {
+	p, err := in.Peek(n)
+	in.off += len(p)
+	if err != nil && len(p) > 0 {
+		err = io.ErrUnexpectedEOF
+	}
+	return bytes.Clone(p), err
+}
+
+// ReadBytes consumes and returns the bytes up to and including the
+// first occurrence of delim, suspending until it arrives. If the
+// input is closed first, ReadBytes returns the remaining bytes and
+// io.EOF. The result is a newly allocated slice.
+func (in *Input) ReadBytes(delim byte) ([]byte, error) {
+	for scanned := 0; ; {
+		if i := bytes.IndexByte(in.buf[in.off+scanned:], delim); i >= 0 {
+			end := in.off + scanned + i + 1
+			p := bytes.Clone(in.buf[in.off:end])
+			in.off = end
+			return p, nil
+		}
+		scanned = in.Buffered()
+		if in.eof {
+			p := bytes.Clone(in.buf[in.off:])
+			in.off = len(in.buf)
+			return p, io.EOF
+		}
+		in.suspend()
+	}
+}
@@ -0,0 +1,141 @@
+package stream
+
+import (
+	"io"
+	"reflect"
+	"testing"
+	"unicode/utf8"
+)
+
+// result is a value read from an Input along with its error.
+type result struct {
+	value any
+	err   error
+}
+
+// feed runs read as a tokenizer over chunks, collecting its results,
+// and closes the input at the end.
+func feed(t *testing.T, read func(in *Input, emit func(result)), chunks ...string) []result {
+	t.Helper()
+	tk := NewTokenizer(func(in *Input, emit func(result)) error {
+		read(in, emit)
+		return nil
+	})
+	var results []result
+	for _, chunk := range chunks {
+		out, err := tk.Feed([]byte(chunk))
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		results = append(results, out...)
+	}
+	out, _ := tk.Close()
+	return append(results, out...)
+}
+
+func TestInputReadRune(t *testing.T) {
+	euro := string([]byte{0xe2, 0x82, 0xac})
+	results := feed(t, func(in *Input, emit func(result)) {
+		for {
+			r, size, err := in.ReadRune()
+			emit(result{[2]int{int(r), size}, err})
+			if err != nil {
+				return
+			}
+		}
+	}, "a"+euro[:1], euro[1:2], euro[2:]+"\xff")
+
+	expected := []result{
+		{[2]int{'a', 1}, nil},
+		{[2]int{'€', 3}, nil},
+		{[2]int{utf8.RuneError, 1}, nil},
+		{[2]int{0, 0}, io.EOF},
+	}
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("Expected %v, got %v", expected, results)
+	}
+}
+
+func TestInputUnreadByte(t *testing.T) {
+	results := feed(t, func(in *Input, emit func(result)) {
+		emit(result{nil, in.UnreadByte()})
+		in.ReadByte()
+		in.ReadByte()
+		// The second byte arrived in a later chunk, after the first
+		// was discarded; unreading it must still work.
+		emit(result{nil, in.UnreadByte()})
+		b, err := in.ReadByte()
+		emit(result{b, err})
+	}, "a", "b")
+
+	expected := []result{{nil, ErrUnread}, {nil, nil}, {byte('b'), nil}}
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("Expected %v, got %v", expected, results)
+	}
+}
+
+func TestInputRead(t *testing.T) {
+	results := feed(t, func(in *Input, emit func(result)) {
+		p := make([]byte, 4)
+		for {
+			n, err := in.Read(p)
+			emit(result{string(p[:n]), err})
+			if err != nil {
+				return
+			}
+		}
+	}, "abcdef", "g")
+
+	expected := []result{{"abcd", nil}, {"ef", nil}, {"g", nil}, {"", io.EOF}}
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("Expected %v, got %v", expected, results)
+	}
+	if n, err := (&Input{}).Read(nil); n != 0 || err != nil {
+		t.Errorf("Expected empty read to succeed, got %d, %v", n, err)
+	}
+}
+
+func TestInputPeekNext(t *testing.T) {
+	results := feed(t, func(in *Input, emit func(result)) {
+		p, err := in.Peek(3)
+		emit(result{string(p), err})
+		p, err = in.Next(3)
+		emit(result{string(p), err})
+		p, err = in.Next(3)
+		emit(result{string(p), err})
+		p, err = in.Next(1)
+		emit(result{string(p), err})
+	}, "a", "bc", "de")
+
+	expected := []result{
+		{"abc", nil},
+		{"abc", nil},
+		{"de", io.ErrUnexpectedEOF},
+		{"", io.EOF},
+	}
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("Expected %v, got %v", expected, results)
+	}
+}
+
+func TestInputReadBytes(t *testing.T) {
+	results := feed(t, func(in *Input, emit func(result)) {
+		for {
+			line, err := in.ReadBytes('\n')
+			emit(result{string(line), err})
+			if err != nil {
+				return
+			}
+		}
+	}, "one\ntw", "o", "\n\nthree")
+
+	expected := []result{
+		{"one\n", nil},
+		{"two\n", nil},
+		{"\n", nil},
+		{"three", io.EOF},
+	}
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("Expected %v, got %v", expected, results)
+	}
+}
@@ -0,0 +1,11 @@
+// Package stream turns incremental input processing into straight
+// line code. A tokenizer is written as an ordinary function that
+// reads bytes or runes from an Input and emits tokens; when it reads
+// past the end of the input received so far, its coroutine suspends
+// until more bytes arrive, instead of the function having to save its
+// progress in a hand-written state machine.
+//
+// Input can be pushed into a Tokenizer in arbitrary fragments with
+// Feed, as is typical when parsing network protocols from partial
+// reads, or pulled from an io.Reader with a Scanner.
+package stream
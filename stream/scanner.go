@@ -0,0 +1,82 @@
+package stream
+
+import (
+	"io"
+
+	"github.com/webriots/coro"
+)
+
+// defaultChunk is the size of the reads a Scanner makes.
+const defaultChunk = 4096
+
+// Scanner pulls tokens from an io.Reader by running a TokenizeFunc
+// over its contents. Bytes are read in chunks only as the tokenizer
+// needs them. A Scanner must not be used from multiple goroutines at
+// once.
+type Scanner[T any] struct {
+	r       io.Reader
+	tk      *Tokenizer[T]
+	buf     []byte
+	pending []T
+	err     error
+}
+
+// NewScanner returns a Scanner that tokenizes the contents of r with
+// fn. Options are passed through to coro.New.
+func NewScanner[T any](r io.Reader, fn TokenizeFunc[T], opts ...coro.Option) *Scanner[T] {
+	return &Scanner[T]{
+		r:   r,
+		tk:  NewTokenizer(fn, opts...),
+		buf: make([]byte, defaultChunk),
+	}
+}
+
+// Next returns the next token. Once the reader and the tokens are
+// exhausted it returns io.EOF; if reading or tokenizing fails, it
+// returns that error instead.
+func (s *Scanner[T]) Next() (T, error) {
+	for len(s.pending) == 0 {
+		if s.err != nil {
+			var zero T
+			return zero, s.err
+		}
+		s.pending, s.err = s.read()
+	}
+	token := s.pending[0]
+	s.pending = s.pending[1:]
+	return token, nil
+}
+
+// Stop abandons the scanner, canceling its tokenizer and discarding
+// any tokens not yet returned.
+func (s *Scanner[T]) Stop() {
+	s.tk.Stop()
+	s.pending = nil
+	if s.err == nil {
+		s.err = io.EOF
+	}
+}
+
+// read reads the next chunk and feeds it to the tokenizer. It returns
+// io.EOF once the tokenizer has finished without error.
+func (s *Scanner[T]) read() ([]T, error) {
+	n, rerr := s.r.Read(s.buf)
+	tokens, err := s.tk.Feed(s.buf[:n])
+	switch {
+	case err != nil:
+		return tokens, err
+	case rerr == io.EOF:
+		rest, err := s.tk.Close()
+		if err == nil {
+			err = io.EOF
+		}
+		return append(tokens, rest...), err
+	case rerr != nil:
+		s.tk.Stop()
+		return tokens, rerr
+	}
+	if s.tk.done {
+		return tokens, io.EOF
+	}
+	return tokens, nil
+}
@@ -0,0 +1,77 @@
+package stream
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func scanAll(s *Scanner[string]) ([]string, error) {
+	var tokens []string
+	for {
+		token, err := s.Next()
+		if err != nil {
+			return tokens, err
+		}
+		tokens = append(tokens, token)
+	}
+}
+
+func TestScanner(t *testing.T) {
+	r := iotest.OneByteReader(strings.NewReader("the quick brown fox"))
+	tokens, err := scanAll(NewScanner(r, words))
+	if err != io.EOF {
+		t.Errorf("Expected io.EOF, got %v", err)
+	}
+	if expected := []string{"the", "quick", "brown", "fox"}; !reflect.DeepEqual(tokens, expected) {
+		t.Errorf("Expected %v, got %v", expected, tokens)
+	}
+}
+
+func TestScannerDataEOF(t *testing.T) {
+	r := iotest.DataErrReader(strings.NewReader("a b"))
+	tokens, err := scanAll(NewScanner(r, words))
+	if err != io.EOF || !reflect.DeepEqual(tokens, []string{"a", "b"}) {
+		t.Errorf("Expected [a b] and io.EOF, got %v, %v", tokens, err)
+	}
+}
+
+func TestScannerTokenizerError(t *testing.T) {
+	tokens, err := scanAll(NewScanner(strings.NewReader("a 1"), words))
+	if err == nil || err.Error() != "unexpected digit" || !reflect.DeepEqual(tokens, []string{"a"}) {
+		t.Errorf("Expected [a] and the tokenizer's error, got %v, %v", tokens, err)
+	}
+}
+
+func TestScannerReadError(t *testing.T) {
+	readErr := errors.New("read failed")
+	r := io.MultiReader(strings.NewReader("a b"), iotest.ErrReader(readErr))
+	tokens, err := scanAll(NewScanner(r, words))
+	if err != readErr || !reflect.DeepEqual(tokens, []string{"a"}) {
+		t.Errorf("Expected [a] and the read error, got %v, %v", tokens, err)
+	}
+}
+
+func TestScannerFinishedEarly(t *testing.T) {
+	first := func(in *Input, emit func(string)) error {
+		line, _ := in.ReadBytes('\n')
+		emit(string(line))
+		return nil
+	}
+	tokens, err := scanAll(NewScanner(strings.NewReader("one\ntwo\n"), first))
+	if err != io.EOF || !reflect.DeepEqual(tokens, []string{"one\n"}) {
+		t.Errorf("Expected [one] and io.EOF, got %v, %v", tokens, err)
+	}
+}
+
+func TestScannerStop(t *testing.T) {
+	s := NewScanner(strings.NewReader("a b c"), words)
+	s.Next()
+	s.Stop()
+	if _, err := s.Next(); err != io.EOF {
+		t.Errorf("Expected io.EOF after Stop, got %v", err)
+	}
+}
@@ -0,0 +1,112 @@
+package stream
+
+import (
+	"errors"
+
+	"github.com/webriots/coro"
+	"github.com/webriots/coro/internal/panics"
+)
+
+// ErrClosed is returned when input is fed to a tokenizer that has
+// been closed or has finished.
+var ErrClosed = errors.New("stream: tokenizer closed")
+
+// TokenizeFunc reads from in and emits each token it recognizes. It
+// is run as a coroutine: reads that need more input than has been fed
+// suspend it until more arrives. Returning ends tokenization, and a
+// non-nil error is reported to the caller of Feed or Close.
+type TokenizeFunc[T any] func(in *Input, emit func(T)) error
+
+// step is what the tokenizer coroutine hands back to Feed: either a
+// token, or, when it returns, its error. Waiting for more input hands
+// back the zero step.
+type step[T any] struct {
+	token T
+	ok    bool
+	err   error
+}
+
+// Tokenizer runs a TokenizeFunc over input that is pushed to it in
+// fragments. A Tokenizer must not be used from multiple goroutines at
+// once.
+type Tokenizer[T any] struct {
+	in     Input
+	resume func(struct{}) (step[T], bool)
+	cancel func()
+	done   bool
+	err    error
+}
+
+// NewTokenizer returns a tokenizer that runs fn over the input fed to
+// it. Options are passed through to coro.New.
+func NewTokenizer[T any](fn TokenizeFunc[T], opts ...coro.Option) *Tokenizer[T] {
+	t := &Tokenizer[T]{}
+	t.resume, t.cancel = coro.New(func(yield func(step[T]) struct{}, _ func() struct{}) step[T] {
+		// Suspending yields the zero step rather than calling suspend,
+		// which would leave the last token as the coroutine's output.
+		t.in.suspend = func() { yield(step[T]{}) }
+		err := fn(&t.in, func(token T) { yield(step[T]{token: token, ok: true}) })
+		return step[T]{err: err}
+	}, opts...)
+	return t
+}
+
+// Feed appends p to the input and runs the tokenizer until it needs
+// more input or finishes, returning the tokens emitted along the way.
+// If the tokenizer finishes, Feed returns its error, and every later
+// call returns ErrClosed if that error was nil. If the tokenizer
+// panics, Feed propagates the panic.
+func (t *Tokenizer[T]) Feed(p []byte) ([]T, error) {
+	if t.done {
+		return nil, t.closedErr()
+	}
+	t.in.push(p)
+	return t.run()
+}
+
+// Close marks the end of the input and runs the tokenizer to
+// completion, returning the remaining tokens and its error. Reads
+// past the end of the input then fail with io.EOF. Calling Close
+// again returns the same error.
+func (t *Tokenizer[T]) Close() ([]T, error) {
+	if t.done {
+		return nil, t.err
+	}
+	t.in.eof = true
+	return t.run()
+}
+
+// Stop abandons the tokenizer without closing its input, canceling
+// its coroutine.
+func (t *Tokenizer[T]) Stop() {
+	if !t.done {
+		t.done = true
+		panics.Cancel(t.cancel)
+	}
+}
+
+// run resumes the tokenizer until it suspends or finishes.
+func (t *Tokenizer[T]) run() ([]T, error) {
+	var tokens []T
+	for {
+		s, running := t.resume(struct{}{})
+		switch {
+		case s.ok:
+			tokens = append(tokens, s.token)
+		case running:
+			return tokens, nil
+		default:
+			t.done, t.err = true, s.err
+			return tokens, s.err
+		}
+	}
+}
+
+// closedErr returns the error reported by Feed once the tokenizer has
+// finished.
+func (t *Tokenizer[T]) closedErr() error {
+	if t.err != nil {
+		return t.err
+	}
+	return ErrClosed
+}
@@ -0,0 +1,138 @@
+package stream
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode"
+)
+
+// words emits each run of letters in the input, and fails on digits.
+func words(in *Input, emit func(string)) error {
+	var word strings.Builder
+	for {
+		r, _, err := in.ReadRune()
+		if err == io.EOF {
+			if word.Len() > 0 {
+				emit(word.String())
+			}
+			return nil
+		}
+		switch {
+		case unicode.IsDigit(r):
+			return errors.New("unexpected digit")
+		case unicode.IsLetter(r):
+			word.WriteRune(r)
+		case word.Len() > 0:
+			emit(word.String())
+			word.Reset()
+		}
+	}
+}
+
+func TestTokenizerFragments(t *testing.T) {
+	input := "héllo, wörld  and more"
+	for size := 1; size <= len(input); size++ {
+		tk := NewTokenizer(words)
+		var tokens []string
+		for i := 0; i < len(input); i += size {
+			out, err := tk.Feed([]byte(input[i:min(i+size, len(input))]))
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			tokens = append(tokens, out...)
+		}
+		out, err := tk.Close()
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		tokens = append(tokens, out...)
+
+		if expected := []string{"héllo", "wörld", "and", "more"}; !reflect.DeepEqual(tokens, expected) {
+			t.Errorf("Fragment size %d: expected %v, got %v", size, expected, tokens)
+		}
+	}
+}
+
+func TestTokenizerIncremental(t *testing.T) {
+	tk := NewTokenizer(words)
+	defer tk.Stop()
+
+	if tokens, _ := tk.Feed([]byte("one tw")); !reflect.DeepEqual(tokens, []string{"one"}) {
+		t.Errorf("Expected [one], got %v", tokens)
+	}
+	if tokens, _ := tk.Feed([]byte("o ")); !reflect.DeepEqual(tokens, []string{"two"}) {
+		t.Errorf("Expected [two], got %v", tokens)
+	}
+	if tokens, _ := tk.Feed(nil); tokens != nil {
+		t.Errorf("Expected no tokens, got %v", tokens)
+	}
+}
+
+func TestTokenizerError(t *testing.T) {
+	tk := NewTokenizer(words)
+
+	tokens, err := tk.Feed([]byte("ab 1 cd"))
+	if !reflect.DeepEqual(tokens, []string{"ab"}) || err == nil || err.Error() != "unexpected digit" {
+		t.Errorf("Expected [ab] and an error, got %v, %v", tokens, err)
+	}
+	if _, err2 := tk.Feed([]byte("x")); err2 != err {
+		t.Errorf("Expected the same error from later feeds, got %v", err2)
+	}
+	if _, err2 := tk.Close(); err2 != err {
+		t.Errorf("Expected the same error from Close, got %v", err2)
+	}
+}
+
+func TestTokenizerClosed(t *testing.T) {
+	tk := NewTokenizer(func(in *Input, emit func(byte)) error {
+		b, err := in.ReadByte()
+		if err == nil {
+			emit(b)
+		}
+		return nil
+	})
+
+	if tokens, err := tk.Feed([]byte("ab")); err != nil || !reflect.DeepEqual(tokens, []byte("a")) {
+		t.Errorf("Expected [a], got %v, %v", tokens, err)
+	}
+	if _, err := tk.Feed([]byte("c")); err != ErrClosed {
+		t.Errorf("Expected ErrClosed, got %v", err)
+	}
+	if _, err := tk.Close(); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+}
+
+func TestTokenizerStop(t *testing.T) {
+	var stopped bool
+	tk := NewTokenizer(func(in *Input, emit func(byte)) error {
+		defer func() { stopped = true }()
+		_, err := in.ReadByte()
+		return err
+	})
+
+	tk.Feed(nil)
+	tk.Stop()
+	tk.Stop()
+	if !stopped {
+		t.Error("Expected the tokenizer to be stopped")
+	}
+	if _, err := tk.Feed([]byte("a")); err != ErrClosed {
+		t.Errorf("Expected ErrClosed, got %v", err)
+	}
+}
+
+func TestTokenizerPanic(t *testing.T) {
+	tk := NewTokenizer(func(in *Input, emit func(byte)) error {
+		panic("test panic")
+	})
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("Expected panic but got none")
+		}
+	}()
+	tk.Feed(nil)
+}
@@ -0,0 +1,114 @@
+package coro
+
+import "context"
+
+// Tracer starts spans. It mirrors the subset of the OpenTelemetry
+// tracer API needed to trace coroutines, so adapting a real tracer
+// takes only a few lines.
+type Tracer interface {
+	// Start begins a span as a child of any span carried by ctx, and
+	// returns a context carrying the new span.
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is a single traced operation started by a Tracer.
+type Span interface {
+	// AddEvent records a named point in time on the span.
+	AddEvent(name string)
+	// RecordError records an error on the span.
+	RecordError(err error)
+	// End completes the span.
+	End()
+}
+
+// Trace records a coroutine as a span, with a child span for each
+// resume segment, that is, the time from a resume until the
+// coroutine next yields, suspends or terminates. Segment spans carry
+// "yield", "complete" and "cancel" events, and panics are recorded
+// as errors on both spans.
+//
+// A Trace belongs to a single coroutine; create one with NewTrace
+// and attach it with WithTrace.
+type Trace struct {
+	tracer  Tracer
+	name    string
+	ctx     context.Context
+	span    Span
+	segCtx  context.Context
+	segment Span
+	done    bool
+}
+
+// NewTrace returns a Trace that records a coroutine span with the
+// given name as a child of any span carried by ctx. The span starts
+// when the Trace is attached with WithTrace.
+func NewTrace(ctx context.Context, tracer Tracer, name string) *Trace {
+	return &Trace{tracer: tracer, name: name, ctx: ctx}
+}
+
+// Context returns the context of the current resume segment's span,
+// so that work done by the coroutine between yields can be traced as
+// its children. Outside of a resume it returns the context of the
+// coroutine span.
+func (t *Trace) Context() context.Context {
+	if t.segCtx != nil {
+		return t.segCtx
+	}
+	return t.ctx
+}
+
+// WithTrace returns an Option that traces the coroutine with t.
+func WithTrace(t *Trace) Option {
+	return func(c *config) {
+		t.ctx, t.span = t.tracer.Start(t.ctx, t.name)
+		c.hooks = c.hooks.then(Hooks{
+			BeforeResume: t.beforeResume,
+			AfterResume:  t.afterResume,
+			OnYield:      t.yield,
+			OnComplete:   t.complete,
+			OnPanic:      t.panic,
+			OnCancel:     t.cancel,
+		})
+	}
+}
+
+func (t *Trace) beforeResume() {
+	t.segCtx, t.segment = t.tracer.Start(t.ctx, t.name+".resume")
+}
+
+func (t *Trace) afterResume() {
+	t.segment.End()
+	t.segCtx, t.segment = nil, nil
+	if t.done {
+		t.span.End()
+	}
+}
+
+func (t *Trace) yield() {
+	// A coroutine that recovers from cancellation may still yield,
+	// outside of any resume segment.
+	if t.segment != nil {
+		t.segment.AddEvent("yield")
+	}
+}
+
+func (t *Trace) complete() {
+	t.segment.AddEvent("complete")
+	t.done = true
+}
+
+func (t *Trace) panic(err error) {
+	t.span.RecordError(err)
+	if t.segment == nil {
+		// The coroutine panicked while handling cancellation.
+		t.span.End()
+		return
+	}
+	t.segment.RecordError(err)
+	t.done = true
+}
+
+func (t *Trace) cancel() {
+	t.span.AddEvent("cancel")
+	t.span.End()
+}
@@ -0,0 +1,137 @@
+package coro
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+// memorySpan is a span recorded by memoryTracer.
+type memorySpan struct {
+	name   string
+	parent *memorySpan
+	events []string
+	errs   []error
+	ended  bool
+}
+
+func (s *memorySpan) AddEvent(name string)  { s.events = append(s.events, name) }
+func (s *memorySpan) RecordError(err error) { s.errs = append(s.errs, err) }
+func (s *memorySpan) End()                  { s.ended = true }
+
+type spanKey struct{}
+
+// memoryTracer is an in-memory exporter that keeps every span it
+// starts, linked to its parent through the context.
+type memoryTracer struct {
+	spans []*memorySpan
+}
+
+func (m *memoryTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	parent, _ := ctx.Value(spanKey{}).(*memorySpan)
+	s := &memorySpan{name: name, parent: parent}
+	m.spans = append(m.spans, s)
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+func TestTraceSegments(t *testing.T) {
+	var tracer memoryTracer
+	ctx, root := tracer.Start(context.Background(), "root")
+	tr := NewTrace(ctx, &tracer, "worker")
+
+	var inner *memorySpan
+	resume, cancel := New(func(yield func(int) int, suspend func() int) int {
+		yield(1)
+		_, span := tracer.Start(tr.Context(), "work")
+		inner = span.(*memorySpan)
+		span.End()
+		suspend()
+		return 2
+	}, WithTrace(tr))
+	defer cancel()
+
+	for {
+		if _, running := resume(0); !running {
+			break
+		}
+	}
+
+	if len(tracer.spans) != 6 {
+		t.Fatalf("Expected 6 spans, got %d", len(tracer.spans))
+	}
+	span := tracer.spans[1]
+	if span.name != "worker" || span.parent != root {
+		t.Errorf("Expected coroutine span 'worker' under root, got '%s'", span.name)
+	}
+	if !span.ended {
+		t.Error("Expected coroutine span to be ended")
+	}
+
+	var events [][]string
+	for _, seg := range tracer.spans[2:] {
+		if seg == inner {
+			continue
+		}
+		if seg.name != "worker.resume" || seg.parent != span || !seg.ended {
+			t.Errorf("Expected ended segment span under coroutine span, got '%s'", seg.name)
+		}
+		events = append(events, seg.events)
+	}
+	expected := [][]string{{"yield"}, {"yield"}, {"complete"}}
+	if !reflect.DeepEqual(events, expected) {
+		t.Errorf("Expected segment events %v, got %v", expected, events)
+	}
+	if inner == nil || inner.parent != tracer.spans[3] {
+		t.Error("Expected work span to be a child of the second segment")
+	}
+	if tr.Context().Value(spanKey{}) != span {
+		t.Error("Expected context outside of resume to carry the coroutine span")
+	}
+}
+
+func TestTracePanic(t *testing.T) {
+	var tracer memoryTracer
+	tr := NewTrace(context.Background(), &tracer, "worker")
+
+	resume, cancel := New(func(yield func(int) int, suspend func() int) int {
+		panic("test panic")
+	}, WithTrace(tr))
+	defer cancel()
+
+	func() {
+		defer func() { _ = recover() }()
+		resume(0)
+	}()
+
+	span, seg := tracer.spans[0], tracer.spans[1]
+	if len(span.errs) != 1 || len(seg.errs) != 1 {
+		t.Fatalf("Expected panic to be recorded on both spans, got %v and %v", span.errs, seg.errs)
+	}
+	if span.errs[0].Error() != "test panic" {
+		t.Errorf("Expected error 'test panic', got '%v'", span.errs[0])
+	}
+	if !span.ended || !seg.ended {
+		t.Error("Expected both spans to be ended")
+	}
+}
+
+func TestTraceCancel(t *testing.T) {
+	var tracer memoryTracer
+	tr := NewTrace(context.Background(), &tracer, "worker")
+
+	resume, cancel := New(func(yield func(int) int, suspend func() int) int {
+		defer func() { _ = recover() }()
+		return yield(1)
+	}, WithTrace(tr))
+
+	resume(0)
+	cancel()
+
+	span := tracer.spans[0]
+	if !reflect.DeepEqual(span.events, []string{"cancel"}) {
+		t.Errorf("Expected cancel event, got %v", span.events)
+	}
+	if !span.ended {
+		t.Error("Expected coroutine span to be ended")
+	}
+}
@@ -0,0 +1,41 @@
+package workflow
+
+import (
+	"fmt"
+
+	"github.com/webriots/coro"
+)
+
+// Context is the handle a workflow function uses to call activities.
+// It must only be used from within the workflow function.
+type Context struct {
+	id    string
+	codec coro.Codec
+	yield func(command) Event
+}
+
+// ID returns the id of the running workflow.
+func (wf *Context) ID() string {
+	return wf.id
+}
+
+// Execute calls the activity called name with in and returns its
+// output. The first time the workflow reaches the call, the engine
+// runs the activity and records its result; when the workflow is
+// replayed, the recorded result is returned without running it
+// again. An activity that fails returns an *ActivityError.
+func Execute[Out, In any](wf *Context, name string, in In) (Out, error) {
+	var out Out
+	input, err := wf.codec.Marshal(in)
+	if err != nil {
+		return out, fmt.Errorf("workflow: encoding input of %s: %w", name, err)
+	}
+	ev := wf.yield(command{activity: name, input: input})
+	if ev.Failed {
+		return out, &ActivityError{Activity: name, Message: ev.Error}
+	}
+	if err := wf.codec.Unmarshal(ev.Output, &out); err != nil {
+		return out, fmt.Errorf("workflow: decoding output of %s: %w", name, err)
+	}
+	return out, nil
+}
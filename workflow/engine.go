@@ -0,0 +1,321 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/webriots/coro"
+	"github.com/webriots/coro/internal/panics"
+)
+
+var (
+	// ErrUnknown is wrapped by the errors for workflows and
+	// activities that have not been registered, and for workflow ids
+	// without a history.
+	ErrUnknown = errors.New("workflow: unknown")
+
+	// ErrConflict is returned by Run when a workflow with the same id
+	// already exists with a different workflow or input.
+	ErrConflict = errors.New("workflow: id already used for a different run")
+)
+
+// WorkflowError is the error of a workflow that failed. Only the
+// message of the workflow's error is kept, so that it can be
+// persisted and returned again after a restart.
+type WorkflowError struct {
+	ID      string
+	Message string
+}
+
+func (e *WorkflowError) Error() string {
+	return fmt.Sprintf("workflow %s failed: %s", e.ID, e.Message)
+}
+
+// ActivityError is the error returned to a workflow by an activity
+// that failed. Only the message of the activity's error is kept, so
+// that a replayed workflow sees the same error as the original run.
+type ActivityError struct {
+	Activity string
+	Message  string
+}
+
+func (e *ActivityError) Error() string {
+	return fmt.Sprintf("activity %s failed: %s", e.Activity, e.Message)
+}
+
+// Option configures an engine.
+type Option func(*Engine)
+
+// WithCodec sets the codec used to encode workflow and activity
+// inputs and outputs. The default is coro.JSONCodec. Replay compares
+// the encoded activity inputs with the history, so the codec must
+// encode equal values identically; coro.GobCodec does not for values
+// containing maps.
+func WithCodec(codec coro.Codec) Option {
+	return func(e *Engine) {
+		e.codec = codec
+	}
+}
+
+// workflowFunc is a registered workflow, operating on encoded values.
+type workflowFunc func(wf *Context, input []byte) ([]byte, error)
+
+// activityFunc is a registered activity, operating on encoded values.
+type activityFunc func(ctx context.Context, input []byte) ([]byte, error)
+
+// Engine runs workflows, recording their progress in a store. An
+// engine runs each workflow on the goroutine that calls Run or
+// Resume, executing its activities one at a time. Workflows and
+// activities must be registered before they are used.
+type Engine struct {
+	store      Store
+	codec      coro.Codec
+	workflows  map[string]workflowFunc
+	activities map[string]activityFunc
+}
+
+// NewEngine returns an engine recording workflows in store.
+func NewEngine(store Store, opts ...Option) *Engine {
+	e := &Engine{
+		store:      store,
+		codec:      coro.JSONCodec,
+		workflows:  make(map[string]workflowFunc),
+		activities: make(map[string]activityFunc),
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(e)
+		}
+	}
+	return e
+}
+
+// RegisterWorkflow registers fn as the workflow called name.
+func RegisterWorkflow[In, Out any](e *Engine, name string, fn func(wf *Context, in In) (Out, error)) {
+	e.workflows[name] = func(wf *Context, input []byte) ([]byte, error) {
+		var in In
+		if err := e.codec.Unmarshal(input, &in); err != nil {
+			return nil, fmt.Errorf("decoding input: %w", err)
+		}
+		out, err := fn(wf, in)
+		if err != nil {
+			return nil, err
+		}
+		return e.codec.Marshal(out)
+	}
+}
+
+// RegisterActivity registers fn as the activity called name.
+func RegisterActivity[In, Out any](e *Engine, name string, fn func(ctx context.Context, in In) (Out, error)) {
+	e.activities[name] = func(ctx context.Context, input []byte) ([]byte, error) {
+		var in In
+		if err := e.codec.Unmarshal(input, &in); err != nil {
+			return nil, fmt.Errorf("decoding input: %w", err)
+		}
+		out, err := fn(ctx, in)
+		if err != nil {
+			return nil, err
+		}
+		return e.codec.Marshal(out)
+	}
+}
+
+// Run runs the workflow called name with input under the given id
+// until it finishes, and decodes its output into result, which may be
+// nil. A failed workflow returns a *WorkflowError.
+//
+// If a workflow with the id already exists, Run resumes it as Resume
+// does, and returns ErrConflict if it was started with a different
+// workflow or input. If ctx is canceled, Run stops before the next
+// activity and returns ctx's error, leaving the workflow to be
+// resumed later.
+func (e *Engine) Run(ctx context.Context, id, name string, input, result any) error {
+	history, err := e.store.Load(id)
+	if err != nil {
+		return err
+	}
+	data, err := e.codec.Marshal(input)
+	if err != nil {
+		return fmt.Errorf("workflow: encoding input: %w", err)
+	}
+	if len(history) == 0 {
+		if _, ok := e.workflows[name]; !ok {
+			return fmt.Errorf("%w workflow %q", ErrUnknown, name)
+		}
+		start := Event{Kind: EventStarted, Name: name, Input: data}
+		if err := e.store.Append(id, start); err != nil {
+			return err
+		}
+		history = []Event{start}
+	} else if history[0].Name != name || string(history[0].Input) != string(data) {
+		return ErrConflict
+	}
+	return e.run(ctx, id, history, result)
+}
+
+// Resume continues workflow id from its history until it finishes,
+// and decodes its output into result, which may be nil. Activities
+// that completed before are not run again. If the workflow has
+// already finished, Resume returns its recorded outcome.
+func (e *Engine) Resume(ctx context.Context, id string, result any) error {
+	history, err := e.store.Load(id)
+	if err != nil {
+		return err
+	}
+	if len(history) == 0 {
+		return fmt.Errorf("%w workflow id %q", ErrUnknown, id)
+	}
+	return e.run(ctx, id, history, result)
+}
+
+// Recover resumes every unfinished workflow in the store, as after a
+// crash, and returns the errors of those that fail, joined.
+func (e *Engine) Recover(ctx context.Context) error {
+	ids, err := e.store.IDs()
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, id := range ids {
+		history, err := e.store.Load(id)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if len(history) == 0 || finished(history) {
+			continue
+		}
+		if err := e.run(ctx, id, history, nil); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// finished reports whether history ends with the workflow's outcome.
+func finished(history []Event) bool {
+	kind := history[len(history)-1].Kind
+	return kind == EventCompleted || kind == EventFailed
+}
+
+// outcome returns the result of a finished workflow.
+func (e *Engine) outcome(id string, last Event, result any) error {
+	if last.Kind == EventFailed {
+		return &WorkflowError{ID: id, Message: last.Error}
+	}
+	if result == nil {
+		return nil
+	}
+	if err := e.codec.Unmarshal(last.Output, result); err != nil {
+		return fmt.Errorf("workflow: decoding output: %w", err)
+	}
+	return nil
+}
+
+// command is what a workflow coroutine yields: a call to an activity,
+// or, when the workflow returns, its outcome.
+type command struct {
+	activity string
+	input    []byte
+	output   []byte
+	err      error
+}
+
+// run replays the history of workflow id, then runs it to completion.
+func (e *Engine) run(ctx context.Context, id string, history []Event, result any) (err error) {
+	if finished(history) {
+		return e.outcome(id, history[len(history)-1], result)
+	}
+	fn, ok := e.workflows[history[0].Name]
+	if !ok {
+		return fmt.Errorf("%w workflow %q", ErrUnknown, history[0].Name)
+	}
+
+	resume, cancel := coro.New(func(yield func(command) Event, _ func() Event) command {
+		out, err := fn(&Context{id: id, codec: e.codec, yield: yield}, history[0].Input)
+		return command{output: out, err: err}
+	}, coro.WithName("workflow "+id))
+	defer func() {
+		defer func() {
+			if p := recover(); p != nil && err == nil {
+				err = fmt.Errorf("workflow %s: %v", id, p)
+			}
+		}()
+		cancel()
+	}()
+
+	cmd, running, err := step(id, resume, Event{})
+	next := 1
+	for ; running && err == nil; next++ {
+		var ev Event
+		switch {
+		case next < len(history):
+			ev = history[next]
+			if ev.Kind != EventActivity || ev.Name != cmd.activity || string(ev.Input) != string(cmd.input) {
+				return fmt.Errorf("%w: workflow %s called activity %s where its history has %s %s",
+					coro.ErrNondeterministic, id, cmd.activity, ev.Kind, ev.Name)
+			}
+		case ctx.Err() != nil:
+			return ctx.Err()
+		default:
+			ev = e.execute(ctx, cmd)
+			// An activity that failed because of shutdown did not
+			// really fail: leave it unrecorded, so that Resume runs it
+			// again. One that completed regardless is recorded.
+			if err := ctx.Err(); err != nil && ev.Failed {
+				return err
+			}
+			if err := e.store.Append(id, ev); err != nil {
+				return err
+			}
+		}
+		cmd, running, err = step(id, resume, ev)
+	}
+	if err != nil {
+		return err
+	}
+	if next < len(history) {
+		return fmt.Errorf("%w: workflow %s finished before the end of its history", coro.ErrNondeterministic, id)
+	}
+
+	last := Event{Kind: EventCompleted, Output: cmd.output}
+	if cmd.err != nil {
+		last = Event{Kind: EventFailed, Error: cmd.err.Error()}
+	}
+	if err := e.store.Append(id, last); err != nil {
+		return err
+	}
+	return e.outcome(id, last, result)
+}
+
+// step resumes a workflow coroutine with ev. A panic in the workflow
+// is returned as an error and is not recorded, so that the workflow
+// can be resumed once its code is fixed.
+func step(id string, resume func(Event) (command, bool), ev Event) (cmd command, running bool, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("workflow %s panicked: %w", id, panics.AsError(p))
+		}
+	}()
+	cmd, running = resume(ev)
+	return cmd, running, nil
+}
+
+// execute runs the activity requested by cmd, returning the event
+// recording its result.
+func (e *Engine) execute(ctx context.Context, cmd command) Event {
+	ev := Event{Kind: EventActivity, Name: cmd.activity, Input: cmd.input}
+	fn, ok := e.activities[cmd.activity]
+	if !ok {
+		ev.Failed, ev.Error = true, fmt.Sprintf("%v activity %q", ErrUnknown, cmd.activity)
+		return ev
+	}
+	out, err := fn(ctx, cmd.input)
+	if err != nil {
+		ev.Failed, ev.Error = true, err.Error()
+		return ev
+	}
+	ev.Output = out
+	return ev
+}
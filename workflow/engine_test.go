@@ -0,0 +1,309 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/webriots/coro"
+)
+
+type order struct {
+	Item  string
+	Price int
+}
+
+// shop registers an order workflow and counts activity executions.
+// The charge activity calls onCharge, if set.
+type shop struct {
+	calls    map[string]int
+	onCharge func()
+}
+
+func newShop(e *Engine) *shop {
+	s := &shop{calls: make(map[string]int)}
+	RegisterActivity(e, "reserve", func(ctx context.Context, item string) (int, error) {
+		s.calls["reserve"]++
+		if item == "unicorn" {
+			return 0, errors.New("out of stock")
+		}
+		return 42, nil
+	})
+	RegisterActivity(e, "charge", func(ctx context.Context, price int) (string, error) {
+		s.calls["charge"]++
+		if s.onCharge != nil {
+			s.onCharge()
+		}
+		return fmt.Sprintf("receipt-%d", price), nil
+	})
+	RegisterActivity(e, "ship", func(ctx context.Context, slot int) (string, error) {
+		s.calls["ship"]++
+		return fmt.Sprintf("parcel-%d", slot), nil
+	})
+	RegisterWorkflow(e, "order", func(wf *Context, o order) (string, error) {
+		slot, err := Execute[int](wf, "reserve", o.Item)
+		if err != nil {
+			return "", err
+		}
+		receipt, err := Execute[string](wf, "charge", o.Price)
+		if err != nil {
+			return "", err
+		}
+		parcel, err := Execute[string](wf, "ship", slot)
+		if err != nil {
+			return "", err
+		}
+		return wf.ID() + ":" + receipt + ":" + parcel, nil
+	})
+	return s
+}
+
+func TestRun(t *testing.T) {
+	store := &MemoryStore{}
+	e := NewEngine(store)
+	s := newShop(e)
+
+	var result string
+	if err := e.Run(context.Background(), "o1", "order", order{"book", 10}, &result); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if result != "o1:receipt-10:parcel-42" {
+		t.Errorf("Expected o1:receipt-10:parcel-42, got %s", result)
+	}
+	history, _ := store.Load("o1")
+	if len(history) != 5 || history[0].Kind != EventStarted || history[4].Kind != EventCompleted {
+		t.Errorf("Expected a complete history, got %v", history)
+	}
+
+	// Running it again returns the recorded result.
+	result = ""
+	if err := e.Run(context.Background(), "o1", "order", order{"book", 10}, &result); err != nil || result != "o1:receipt-10:parcel-42" {
+		t.Errorf("Expected the recorded result, got %s, %v", result, err)
+	}
+	if s.calls["charge"] != 1 {
+		t.Errorf("Expected one charge, got %d", s.calls["charge"])
+	}
+}
+
+func TestResumeAfterCrash(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// The process "crashes" right after charging.
+	ctx, crash := context.WithCancel(context.Background())
+	e := NewEngine(store)
+	s := newShop(e)
+	s.onCharge = crash
+	if err := e.Run(ctx, "o1", "order", order{"book", 10}, nil); err != context.Canceled {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+	if s.calls["ship"] != 0 {
+		t.Fatal("Expected the workflow to stop before shipping")
+	}
+
+	// A new engine resumes from the persisted history without
+	// repeating the charge.
+	e = NewEngine(store)
+	s = newShop(e)
+	var result string
+	if err := e.Resume(context.Background(), "o1", &result); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if result != "o1:receipt-10:parcel-42" {
+		t.Errorf("Expected o1:receipt-10:parcel-42, got %s", result)
+	}
+	if s.calls["reserve"] != 0 || s.calls["charge"] != 0 || s.calls["ship"] != 1 {
+		t.Errorf("Expected only the ship activity to run, got %v", s.calls)
+	}
+}
+
+func TestShutdownDuringActivity(t *testing.T) {
+	store := &MemoryStore{}
+	newEngine := func(activity func(ctx context.Context, n int) (int, error)) *Engine {
+		e := NewEngine(store)
+		RegisterActivity(e, "step", activity)
+		RegisterWorkflow(e, "w", func(wf *Context, n int) (int, error) {
+			return Execute[int](wf, "step", n)
+		})
+		return e
+	}
+
+	// The activity fails only because the engine is shutting down.
+	ctx, shutdown := context.WithCancel(context.Background())
+	shutdown()
+	e := newEngine(func(ctx context.Context, n int) (int, error) {
+		<-ctx.Done()
+		return 0, ctx.Err()
+	})
+	if err := e.Run(ctx, "w1", "w", 1, nil); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+	if history, _ := store.Load("w1"); len(history) != 1 {
+		t.Errorf("Expected only the start to be recorded, got %v", history)
+	}
+
+	// Resuming runs it again rather than replaying a failure.
+	e = newEngine(func(ctx context.Context, n int) (int, error) {
+		return n + 1, nil
+	})
+	var result int
+	if err := e.Resume(context.Background(), "w1", &result); err != nil || result != 2 {
+		t.Errorf("Expected 2, got %d, %v", result, err)
+	}
+}
+
+func TestRecover(t *testing.T) {
+	store := &MemoryStore{}
+	ctx, crash := context.WithCancel(context.Background())
+	e := NewEngine(store)
+	s := newShop(e)
+	s.onCharge = crash
+	e.Run(ctx, "o1", "order", order{"book", 10}, nil)
+	e.Run(ctx, "o2", "order", order{"pen", 2}, nil)
+	e.Run(context.Background(), "o3", "order", order{"unicorn", 1}, nil)
+
+	e = NewEngine(store)
+	s = newShop(e)
+	if err := e.Recover(context.Background()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if s.calls["charge"] != 1 || s.calls["ship"] != 2 {
+		t.Errorf("Expected the two unfinished workflows to complete, got %v", s.calls)
+	}
+	for _, id := range []string{"o1", "o2"} {
+		if history, _ := store.Load(id); !finished(history) {
+			t.Errorf("Expected %s to be finished", id)
+		}
+	}
+}
+
+func TestFailures(t *testing.T) {
+	e := NewEngine(&MemoryStore{}, WithCodec(coro.GobCodec))
+	newShop(e)
+
+	err := e.Run(context.Background(), "o1", "order", order{"unicorn", 1}, nil)
+	var werr *WorkflowError
+	if !errors.As(err, &werr) || werr.ID != "o1" || werr.Message != "activity reserve failed: out of stock" {
+		t.Errorf("Expected the workflow to fail with the activity's error, got %v", err)
+	}
+	if err2 := e.Resume(context.Background(), "o1", nil); err2 == nil || err2.Error() != err.Error() {
+		t.Errorf("Expected the recorded failure, got %v", err2)
+	}
+
+	RegisterWorkflow(e, "typo", func(wf *Context, in int) (int, error) {
+		_, err := Execute[int](wf, "missing", in)
+		var aerr *ActivityError
+		if !errors.As(err, &aerr) || aerr.Activity != "missing" || !strings.Contains(aerr.Message, "unknown") {
+			t.Errorf("Expected an unknown activity error, got %v", err)
+		}
+		return 0, nil
+	})
+	if err := e.Run(context.Background(), "t1", "typo", 1, nil); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+}
+
+func TestEmptyActivityError(t *testing.T) {
+	store := &MemoryStore{}
+	e := NewEngine(store)
+	RegisterActivity(e, "quiet", func(ctx context.Context, in int) (int, error) {
+		return 0, errors.New("")
+	})
+	RegisterWorkflow(e, "wf", func(wf *Context, in int) (bool, error) {
+		_, err := Execute[int](wf, "quiet", in)
+		var aerr *ActivityError
+		return errors.As(err, &aerr) && aerr.Message == "", nil
+	})
+
+	var failed bool
+	if err := e.Run(context.Background(), "q1", "wf", 1, &failed); err != nil || !failed {
+		t.Errorf("Expected the activity to fail with an empty message, got %v, %v", failed, err)
+	}
+	history, _ := store.Load("q1")
+	if len(history) != 3 || !history[1].Failed {
+		t.Errorf("Expected the failure to be recorded, got %+v", history)
+	}
+
+	// Replaying the recorded failure returns it again.
+	e2 := NewEngine(&MemoryStore{histories: map[string][]Event{"q1": history[:2]}})
+	RegisterWorkflow(e2, "wf", func(wf *Context, in int) (bool, error) {
+		_, err := Execute[int](wf, "quiet", in)
+		return err != nil, nil
+	})
+	if err := e2.Resume(context.Background(), "q1", &failed); err != nil || !failed {
+		t.Errorf("Expected the replayed activity to fail, got %v, %v", failed, err)
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	e := NewEngine(&MemoryStore{})
+	newShop(e)
+
+	if err := e.Run(context.Background(), "x", "missing", 1, nil); !errors.Is(err, ErrUnknown) {
+		t.Errorf("Expected ErrUnknown, got %v", err)
+	}
+	if err := e.Resume(context.Background(), "x", nil); !errors.Is(err, ErrUnknown) {
+		t.Errorf("Expected ErrUnknown, got %v", err)
+	}
+	e.Run(context.Background(), "o1", "order", order{"book", 10}, nil)
+	if err := e.Run(context.Background(), "o1", "order", order{"book", 11}, nil); err != ErrConflict {
+		t.Errorf("Expected ErrConflict, got %v", err)
+	}
+	if err := e.Run(context.Background(), "o2", "order", func() {}, nil); err == nil {
+		t.Error("Expected an error for an unencodable input")
+	}
+}
+
+func TestNondeterminism(t *testing.T) {
+	store := &MemoryStore{}
+	ctx, crash := context.WithCancel(context.Background())
+	e := NewEngine(store)
+	s := newShop(e)
+	s.onCharge = crash
+	e.Run(ctx, "o1", "order", order{"book", 10}, nil)
+
+	// The workflow's code changed between runs.
+	e = NewEngine(store)
+	newShop(e)
+	RegisterWorkflow(e, "order", func(wf *Context, o order) (string, error) {
+		Execute[string](wf, "charge", o.Price)
+		return "", nil
+	})
+	if err := e.Resume(context.Background(), "o1", nil); !errors.Is(err, coro.ErrNondeterministic) {
+		t.Errorf("Expected ErrNondeterministic, got %v", err)
+	}
+
+	RegisterWorkflow(e, "order", func(wf *Context, o order) (string, error) {
+		return "", nil
+	})
+	if err := e.Resume(context.Background(), "o1", nil); !errors.Is(err, coro.ErrNondeterministic) {
+		t.Errorf("Expected ErrNondeterministic for a shorter run, got %v", err)
+	}
+}
+
+func TestWorkflowPanic(t *testing.T) {
+	store := &MemoryStore{}
+	e := NewEngine(store)
+	newShop(e)
+	RegisterWorkflow(e, "buggy", func(wf *Context, in int) (int, error) {
+		Execute[int](wf, "reserve", "book")
+		panic("bug")
+	})
+
+	if err := e.Run(context.Background(), "b1", "buggy", 1, nil); err == nil || !strings.Contains(err.Error(), "panicked: bug") {
+		t.Fatalf("Expected the panic as an error, got %v", err)
+	}
+
+	// After a fix, the workflow resumes from where it was.
+	RegisterWorkflow(e, "buggy", func(wf *Context, in int) (int, error) {
+		return Execute[int](wf, "reserve", "book")
+	})
+	var result int
+	if err := e.Resume(context.Background(), "b1", &result); err != nil || result != 42 {
+		t.Errorf("Expected 42, got %d, %v", result, err)
+	}
+}
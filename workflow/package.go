@@ -0,0 +1,16 @@
+// Package workflow is a durable workflow engine built on coroutines.
+// A workflow is an ordinary function run as a coroutine. Each call it
+// makes to an activity, the part of the work with side effects, is
+// yielded to the engine as a command; the engine runs the activity
+// and appends its result to the workflow's history in a Store before
+// resuming the workflow with it.
+//
+// If the process stops part way through a workflow, a new engine
+// resumes it by replaying its history: the workflow function runs
+// again from the start, but activities that already completed return
+// their recorded results instead of running again. Workflow functions
+// must therefore be deterministic, and do everything
+// nondeterministic, such as I/O or reading the clock, in activities.
+// A workflow that issues different activities than its history
+// records fails with an error wrapping coro.ErrNondeterministic.
+package workflow
@@ -0,0 +1,210 @@
+package workflow
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Event kinds recorded in a workflow's history.
+const (
+	EventStarted   = "started"
+	EventActivity  = "activity"
+	EventCompleted = "completed"
+	EventFailed    = "failed"
+)
+
+// Event is an entry in a workflow's history. Inputs and outputs are
+// encoded with the engine's codec. Failed marks an activity that
+// failed, with the message of its error, which may be empty, in Error.
+type Event struct {
+	Kind   string `json:"kind"`
+	Name   string `json:"name,omitempty"`
+	Input  []byte `json:"input,omitempty"`
+	Output []byte `json:"output,omitempty"`
+	Failed bool   `json:"failed,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Store persists the histories of workflows. Implementations must be
+// safe for concurrent use.
+type Store interface {
+	// Append adds e to the end of the history of workflow id.
+	Append(id string, e Event) error
+	// Load returns the history of workflow id, which is empty if the
+	// workflow does not exist.
+	Load(id string) ([]Event, error)
+	// IDs returns the ids of all workflows with a history.
+	IDs() ([]string, error)
+}
+
+// MemoryStore is a Store that keeps histories in memory. Its zero
+// value is ready to use.
+type MemoryStore struct {
+	mu        sync.Mutex
+	histories map[string][]Event
+}
+
+// Append adds e to the end of the history of workflow id.
+func (s *MemoryStore) Append(id string, e Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.histories == nil {
+		s.histories = make(map[string][]Event)
+	}
+	s.histories[id] = append(s.histories[id], e)
+	return nil
+}
+
+// Load returns a copy of the history of workflow id.
+func (s *MemoryStore) Load(id string) ([]Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.histories[id]), nil
+}
+
+// IDs returns the ids of all workflows with a history, sorted.
+func (s *MemoryStore) IDs() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ids := make([]string, 0, len(s.histories))
+	for id := range s.histories {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	return ids, nil
+}
+
+// historyExt is the extension of the history files of a FileStore.
+const historyExt = ".jsonl"
+
+// FileStore is a Store that keeps each workflow's history in a file
+// of JSON lines in a directory. Appends are synced to disk before
+// they return, and a final line left incomplete by a crash is
+// ignored.
+type FileStore struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// NewFileStore returns a FileStore keeping histories in dir, creating
+// it if needed.
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+// path returns the name of the history file of workflow id.
+func (s *FileStore) path(id string) string {
+	return filepath.Join(s.dir, url.PathEscape(id)+historyExt)
+}
+
+// Append adds e to the end of the history of workflow id.
+func (s *FileStore) Append(id string, e Event) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := os.OpenFile(s.path(id), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	end, err := truncatePartial(f)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.WriteAt(append(line, '\n'), end); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// truncatePartial removes a partial last line left in f by a crash
+// during Append, so that the next event starts on a line of its own,
+// and returns the resulting size of f.
+func truncatePartial(f *os.File) (int64, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	size := info.Size()
+	if size == 0 {
+		return 0, nil
+	}
+	last := make([]byte, 1)
+	if _, err := f.ReadAt(last, size-1); err != nil {
+		return 0, err
+	}
+	if last[0] == '\n' {
+		return size, nil
+	}
+	data := make([]byte, size)
+	if _, err := f.ReadAt(data, 0); err != nil {
+		return 0, err
+	}
+	size = int64(bytes.LastIndexByte(data, '\n') + 1)
+	return size, f.Truncate(size)
+}
+
+// Load returns the history of workflow id.
+func (s *FileStore) Load(id string) ([]Event, error) {
+	s.mu.Lock()
+	data, err := os.ReadFile(s.path(id))
+	s.mu.Unlock()
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// A crash during Append can leave a partial last line.
+	data = data[:bytes.LastIndexByte(data, '\n')+1]
+	var events []Event
+	for _, line := range bytes.SplitAfter(data, []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		var e Event
+		if err := json.Unmarshal(line, &e); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, nil
+}
+
+// IDs returns the ids of all workflows with a history file, sorted.
+func (s *FileStore) IDs() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), historyExt)
+		if !ok || entry.IsDir() {
+			continue
+		}
+		if id, err := url.PathUnescape(name); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
@@ -0,0 +1,84 @@
+package workflow
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func testStore(t *testing.T, s Store) {
+	t.Helper()
+	events := []Event{
+		{Kind: EventStarted, Name: "order", Input: []byte(`{"id":1}`)},
+		{Kind: EventActivity, Name: "charge", Input: []byte(`1`), Error: "declined"},
+	}
+	for _, e := range events {
+		if err := s.Append("orders/1", e); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+	s.Append("b", Event{Kind: EventStarted})
+
+	loaded, err := s.Load("orders/1")
+	if err != nil || !reflect.DeepEqual(loaded, events) {
+		t.Errorf("Expected %v, got %v, %v", events, loaded, err)
+	}
+	if loaded, err := s.Load("missing"); err != nil || len(loaded) != 0 {
+		t.Errorf("Expected an empty history, got %v, %v", loaded, err)
+	}
+	if ids, err := s.IDs(); err != nil || !reflect.DeepEqual(ids, []string{"b", "orders/1"}) {
+		t.Errorf("Expected [b orders/1], got %v, %v", ids, err)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, &MemoryStore{})
+}
+
+func TestFileStore(t *testing.T) {
+	s, err := NewFileStore(filepath.Join(t.TempDir(), "histories"))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	testStore(t, s)
+}
+
+func TestFileStoreTornWrite(t *testing.T) {
+	dir := t.TempDir()
+	s, _ := NewFileStore(dir)
+	s.Append("w", Event{Kind: EventStarted, Name: "w"})
+
+	// Simulate a crash part way through appending an event.
+	f, _ := os.OpenFile(s.path("w"), os.O_WRONLY|os.O_APPEND, 0)
+	f.WriteString(`{"kind":"activ`)
+	f.Close()
+
+	events, err := s.Load("w")
+	if err != nil || len(events) != 1 {
+		t.Errorf("Expected the partial event to be ignored, got %v, %v", events, err)
+	}
+
+	// Recovery appends after the last complete event, dropping the
+	// partial one.
+	completed := Event{Kind: EventCompleted, Name: "w", Output: []byte(`1`)}
+	if err := s.Append("w", completed); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	events, err = s.Load("w")
+	if expected := []Event{{Kind: EventStarted, Name: "w"}, completed}; err != nil || !reflect.DeepEqual(events, expected) {
+		t.Errorf("Expected %v, got %v, %v", expected, events, err)
+	}
+
+	// A crash during the very first append leaves no complete line.
+	os.WriteFile(s.path("first"), []byte(`{"kind":"sta`), 0o644)
+	s.Append("first", completed)
+	if events, err := s.Load("first"); err != nil || !reflect.DeepEqual(events, []Event{completed}) {
+		t.Errorf("Expected only the recovered event, got %v, %v", events, err)
+	}
+
+	os.WriteFile(s.path("bad"), []byte("not json\n"), 0o644)
+	if _, err := s.Load("bad"); err == nil {
+		t.Error("Expected an error for a corrupt history")
+	}
+}